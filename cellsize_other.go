@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+// cellSize falls back to a typical 8x16 cell where the terminal's pixel size can't be queried.
+func cellSize() (int, int) {
+	return 8, 16
+}
@@ -0,0 +1,85 @@
+// NOTE: Code previews: the lexer is picked from the file name or content and tokens take their colors from the Theme
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// previewLexer picks a lexer from the file name, then by running chroma's analysers over the content, then from
+// the interpreter named on a "#!" line, which the analysers only know for a few languages.
+func previewLexer(path, content string) chroma.Lexer {
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		lexer = lexers.Analyse(content)
+	}
+	if lexer == nil {
+		lexer = shebangLexer(content)
+	}
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// shebangLexer looks up the interpreter of a script, e.g. "#!/usr/bin/env python3" finds Python.
+func shebangLexer(content string) chroma.Lexer {
+	first, _, _ := strings.Cut(content, "\n")
+	if !strings.HasPrefix(first, "#!") {
+		return nil
+	}
+	var name string
+	for _, field := range strings.Fields(strings.TrimPrefix(first, "#!")) {
+		// Skip env and its flags, e.g. "#!/usr/bin/env -S ruby -w"
+		if base := filepath.Base(field); base != "env" && !strings.HasPrefix(field, "-") {
+			name = base
+			break
+		}
+	}
+	if name == "node" || name == "nodejs" {
+		name = "javascript"
+	}
+	if lexer := lexers.Get(name); lexer != nil {
+		return lexer
+	}
+	return lexers.Get(strings.TrimRight(name, "0123456789."))
+}
+
+// NOTE: highlightCode colors source with the theme's token styles, optionally behind a line number gutter.
+// Tabs are expanded so the preview's wrapping sees the width they take on screen.
+func highlightCode(lexer chroma.Lexer, content string, theme Theme, lineNumbers bool) string {
+	content = strings.ReplaceAll(strings.TrimSuffix(content, "\n"), "\t", "    ")
+	it, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return content
+	}
+
+	lines := []string{""}
+	for tok := it(); tok != chroma.EOF; tok = it() {
+		style, ok := theme.tokenStyle(tok.Type)
+		for i, part := range strings.Split(tok.Value, "\n") {
+			if i > 0 {
+				lines = append(lines, "")
+			}
+			if ok && part != "" {
+				part = style.Render(part)
+			}
+			lines[len(lines)-1] += part
+		}
+	}
+
+	if lineNumbers {
+		gutter := lipgloss.NewStyle().Foreground(theme.Muted)
+		width := len(fmt.Sprint(len(lines)))
+		for i, line := range lines {
+			lines[i] = gutter.Render(fmt.Sprintf("%*d │ ", width, i+1)) + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,57 @@
+// NOTE: Optional per-vault settings, read from <vault>/.config.json
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// duration is a time.Duration that reads from JSON strings like "30s" or "2m".
+type duration time.Duration
+
+func (d *duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"30s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+type config struct {
+	// Autosave saves the inbuilt editor's buffer after this much idle time. Zero disables it.
+	Autosave duration `json:"autosave"`
+	// WordGoals is a daily word target per journal folder, e.g. {"daily": 750}.
+	WordGoals map[string]int `json:"word_goals"`
+	// Dictionary is a Hunspell .dic file (with its .aff next to it) or a word list. Spell checking is off when empty.
+	Dictionary string `json:"dictionary"`
+	// SpellPreview also underlines misspelled words in the markdown preview.
+	SpellPreview bool `json:"spell_preview"`
+	// Graphics forces the image backend: auto, kitty, sixel, iterm2, halfblock, braille or none.
+	Graphics string `json:"graphics"`
+	// ImageCacheMB caps the memory used by encoded image previews. Zero uses the default of 64 MB.
+	ImageCacheMB int `json:"image_cache_mb"`
+}
+
+var cfg config
+
+// loadConfig reads the vault config. A missing file leaves the defaults in place.
+func loadConfig() error {
+	data, err := os.ReadFile(filepath.Join(vaultDir, ".config.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &cfg)
+}
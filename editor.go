@@ -5,6 +5,8 @@ package main
 import (
 	"os"
 	"os/exec"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/textarea"
 	tea "github.com/charmbracelet/bubbletea"
@@ -17,8 +19,9 @@ func openInbuiltEditor(path string, m model) (model, tea.Cmd) {
 	}
 
 	ta := textarea.New()
+	ta.KeyMap = editorKeyMap()
 	ta.ShowLineNumbers = true
-	ta.SetWidth(m.width)
+	ta.MaxHeight = 0
 	ta.SetHeight(m.height - 4)
 	ta.SetValue(string(content))
 	ta.Focus()
@@ -26,19 +29,173 @@ func openInbuiltEditor(path string, m model) (model, tea.Cmd) {
 	m.editorMode = true
 	m.editorFile = path
 	m.editorContent = ta
+	m.editorSaved = ta.Value()
+	m.editorRev++
+	m.editorHistory = editHistory{}
+	m.editorTop = 0
+	m.search.active = false
+	m.vim = vimState{}
+	m.spellMenu = spellMenu{}
+	stats, statsErr := loadWordStats()
+	m.wordStats = stats
+	m = m.resizeEditor()
+	m.editorStatus = ""
+	if statsErr != nil {
+		m.editorStatus = "Word history not loaded: " + statsErr.Error()
+	}
+	m.editorConfirm = false
 
 	m.list.SetItems(listFiles(m.sortMode, m.yapMode))
 
+	if m.editorPreview {
+		return m, m.renderEditorPreview(m.editorRev)
+	}
 	return m, nil
 }
 
-func saveEditorContent(path, content string) tea.Cmd {
+// NOTE: editorKeyMap is the textarea's default keymap without the keys the editor takes for its own commands, so no key
+// does two things at once. The arrow keys still move the cursor.
+func editorKeyMap() textarea.KeyMap {
+	km := textarea.DefaultKeyMap
+	km.CharacterForward.SetKeys("right")            // ctrl+f finds
+	km.LinePrevious.SetKeys("up")                   // ctrl+p toggles the preview
+	km.WordBackward.SetKeys("alt+left")             // alt+b makes bold
+	km.TransposeCharacterBackward.SetEnabled(false) // ctrl+t toggles a checkbox
+	return km
+}
+
+// NOTE: editorDirty reports whether the buffer differs from what was last loaded or saved.
+func (m model) editorDirty() bool {
+	return m.editorContent.Value() != m.editorSaved
+}
+
+// NOTE: updateEditor handles keys while the inbuilt editor is open, including the save/discard/cancel prompt on close.
+func (m model) updateEditor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.editorConfirm {
+		switch msg.String() {
+		case "s", "S", "y", "Y":
+			m.editorConfirm = false
+			return m, saveEditorContent(m.editorFile, m.editorContent.Value(), true, false)
+		case "d", "D", "n", "N":
+			m.editorConfirm = false
+			return m.closeEditor()
+		case "c", "C", "esc":
+			m.editorConfirm = false
+		}
+		return m, nil
+	}
+
+	if m.spellMenu.active {
+		return m.updateSpellMenu(msg)
+	}
+
+	if m.search.active {
+		return m.updateSearch(msg)
+	}
+
+	switch msg.String() {
+	case "ctrl+f":
+		m.search.active = true
+		m.search.replacing = false
+		m.search.replace.Blur()
+		m = m.refreshSearch(true)
+		return m, m.search.query.Focus()
+	case "ctrl+p":
+		return m.toggleEditorPreview()
+	case "ctrl+l":
+		return m.openSpellMenu()
+	case "ctrl+s":
+		return m, saveEditorContent(m.editorFile, m.editorContent.Value(), false, false)
+	case "ctrl+z":
+		return m.undoEdit()
+	case "ctrl+y":
+		return m.redoEdit()
+	case "ctrl+q":
+		if m.editorDirty() {
+			m.editorConfirm = true
+			return m, nil
+		}
+		return m.closeEditor()
+	}
+
+	m.editorStatus = ""
+	if md, cmd, ok := m.updateMarkdown(msg); ok {
+		return md, cmd
+	}
+	if vimEnabled {
+		if m.vim.mode != vimInsert {
+			return m.updateVim(msg)
+		}
+		if msg.String() == "esc" {
+			m.vim.mode = vimNormal
+			b, off := m.vimBufferState()
+			if off > b.lineStart(off) {
+				off--
+			}
+			return m.vimMoveTo(b, off), nil
+		}
+	}
+	if md, cmd, ok := m.updateListKeys(msg); ok {
+		return md, cmd
+	}
+	return m.updateEditorContent(msg)
+}
+
+// NOTE: updateEditorContent passes a message to the textarea and restarts the swap/autosave timers when the text changed.
+func (m model) updateEditorContent(msg tea.Msg) (model, tea.Cmd) {
+	before := snapshotEditor(m.editorContent)
+	var editorCmd tea.Cmd
+	m.editorContent, editorCmd = m.editorContent.Update(msg)
+	if m.editorContent.Value() == before.value {
+		return m.followCursor(), editorCmd
+	}
+	kind, forceBreak := classifyEdit(msg, before.value, before.col, before.row)
+	m.editorHistory.record(before, kind, forceBreak, time.Now())
+	m.editorRev++
+	return m.followCursor(), tea.Batch(editorCmd, scheduleEditorTicks(m.editorRev))
+}
+
+// lineAt returns the given line of a buffer, or "" when out of range.
+func lineAt(value string, row int) string {
+	lines := strings.Split(value, "\n")
+	if row < 0 || row >= len(lines) {
+		return ""
+	}
+	return lines[row]
+}
+
+// setEditorCursor moves the textarea cursor to a logical row and column.
+// textarea only moves between rows one step at a time, so walk there.
+func setEditorCursor(ta *textarea.Model, row, col int) {
+	row = max(0, min(row, ta.LineCount()-1))
+	for ta.Line() > row {
+		ta.CursorUp()
+	}
+	for ta.Line() < row {
+		ta.CursorDown()
+	}
+	ta.SetCursor(col)
+}
+
+// NOTE: closeEditor leaves editor mode and reloads the list and preview.
+func (m model) closeEditor() (tea.Model, tea.Cmd) {
+	removeSwap(m.editorFile)
+	m.editorMode = false
+	m.editorConfirm = false
+	m.editorStatus = ""
+	m.editorContent.Blur()
+	m.list.SetItems(listFiles(m.sortMode, m.yapMode))
+	if m.showPreview {
+		m.loadingFile = true
+		return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
+	}
+	return m, nil
+}
+
+func saveEditorContent(path, content string, close, auto bool) tea.Cmd {
 	return func() tea.Msg {
 		err := os.WriteFile(path, []byte(content), 0o644)
-		if err != nil {
-			return editorSavedMsg{}
-		}
-		return editorSavedMsg{}
+		return editorSavedMsg{content: content, err: err, close: close, auto: auto}
 	}
 }
 
@@ -0,0 +1,83 @@
+// NOTE: Side-by-side live markdown preview for the inbuilt editor (ctrl+p)
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/reflow/wordwrap"
+)
+
+// editorPreviewDelay debounces re-rendering the preview while typing.
+const editorPreviewDelay = 300 * time.Millisecond
+
+type (
+	editorPreviewTickMsg struct{ rev int }
+	editorPreviewMsg     struct {
+		rev     int
+		content string
+	}
+)
+
+// editorPreviewWidth is the width of the preview pane, to the right of the editor and its separator.
+func (m model) editorPreviewWidth() int {
+	return max(1, m.width-m.editorWidth()-3)
+}
+
+// renderEditorPreview renders the buffer with glamour in the background.
+func (m model) renderEditorPreview(rev int) tea.Cmd {
+	path, content := m.editorFile, m.editorContent.Value()
+	width, height := m.editorPreviewWidth(), m.editorBodyHeight()
+	return func() tea.Msg {
+		rendered := renderNote(path, content, width, height)
+		return editorPreviewMsg{rev: rev, content: wordwrap.String(rendered, width)}
+	}
+}
+
+// NOTE: toggleEditorPreview splits the screen between the editor and a rendered preview, or gives the editor the full width back.
+func (m model) toggleEditorPreview() (tea.Model, tea.Cmd) {
+	m.editorPreview = !m.editorPreview
+	m = m.resizeEditor()
+	if !m.editorPreview {
+		return m, nil
+	}
+	return m, m.renderEditorPreview(m.editorRev)
+}
+
+// resizeEditor fits the textarea and preview pane to the window and the current split.
+func (m model) resizeEditor() model {
+	m.editorContent.SetWidth(m.editorWidth())
+	if m.editorPreview {
+		if m.editorPreviewPane.Width == 0 {
+			m.editorPreviewPane = viewport.New(0, 0)
+		}
+		m.editorPreviewPane.Width = m.editorPreviewWidth()
+		m.editorPreviewPane.Height = m.editorBodyHeight()
+	}
+	return m.followCursor()
+}
+
+// NOTE: syncEditorPreview scrolls the preview so the cursor's position in the buffer maps to about the middle of the pane.
+func (m model) syncEditorPreview() model {
+	if !m.editorPreview {
+		return m
+	}
+	row, _ := m.editorCursor()
+	lines := max(1, m.editorContent.LineCount())
+	total := m.editorPreviewPane.TotalLineCount()
+	target := total*row/lines - m.editorPreviewPane.Height/2
+	m.editorPreviewPane.SetYOffset(max(0, target))
+	return m
+}
+
+// editorPreviewView draws the preview pane next to the editor with a separator.
+func (m model) editorPreviewView(editor string) string {
+	height := m.editorBodyHeight()
+	sep := lipgloss.NewStyle().Foreground(m.theme.Border).Render(strings.TrimSuffix(strings.Repeat(" │\n", height), "\n"))
+	pane := lipgloss.NewStyle().Width(m.editorPreviewWidth()).Height(height).MaxHeight(height).Render(m.editorPreviewPane.View())
+	return lipgloss.JoinHorizontal(lipgloss.Top, lipgloss.NewStyle().Width(m.editorWidth()).Render(editor), sep, " ", pane)
+}
@@ -0,0 +1,219 @@
+// NOTE: Rendering for the inbuilt editor. The textarea keeps the text and cursor, this draws them so matches and other spans can be styled
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-runewidth"
+)
+
+// editorNumberWidth is the number of digits in the editor's line numbers, at least 3 like textarea's own.
+func (m model) editorNumberWidth() int {
+	return max(3, len(fmt.Sprint(m.editorContent.LineCount())))
+}
+
+// editorGutter is the width of the "┃ 123 " prefix, the prompt plus line numbers.
+func (m model) editorGutter() int {
+	return 3 + m.editorNumberWidth()
+}
+
+// span styles the runes [start, end) of one line. Later spans win over earlier ones.
+type span struct {
+	start int
+	end   int
+	style lipgloss.Style
+}
+
+// editorCursor returns the logical row and rune column of the textarea cursor.
+func (m model) editorCursor() (int, int) {
+	li := m.editorContent.LineInfo()
+	return m.editorContent.Line(), li.StartColumn + li.ColumnOffset
+}
+
+// wrapRunes splits a line into chunks no wider than width cells, returning the rune offset of each chunk.
+func wrapRunes(line []rune, width int) []int {
+	starts := []int{0}
+	w := 0
+	for i, r := range line {
+		rw := runewidth.RuneWidth(r)
+		if w+rw > width && i > starts[len(starts)-1] {
+			starts = append(starts, i)
+			w = 0
+		}
+		w += rw
+	}
+	return starts
+}
+
+// NOTE: followCursor scrolls the editor so that the cursor's visual line is on screen, and keeps the live preview in step.
+func (m model) followCursor() model {
+	height := m.editorBodyHeight()
+	width := max(1, m.editorTextWidth())
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	row, col := m.editorCursor()
+
+	if row < m.editorTop {
+		m.editorTop = row
+		return m.syncEditorPreview()
+	}
+	m.editorTop = min(m.editorTop, len(lines)-1)
+
+	// Count the visual lines from the top row down to the cursor and scroll until it fits
+	visual := func(from int) int {
+		n := 0
+		for r := from; r < row; r++ {
+			n += len(wrapRunes([]rune(lines[r]), width))
+		}
+		starts := wrapRunes([]rune(lines[row]), width)
+		for i := len(starts) - 1; i >= 0; i-- {
+			if col >= starts[i] {
+				return n + i + 1
+			}
+		}
+		return n + 1
+	}
+	for m.editorTop < row && visual(m.editorTop) > height {
+		m.editorTop++
+	}
+	return m.syncEditorPreview()
+}
+
+func (m model) editorTextWidth() int {
+	return m.editorWidth() - m.editorGutter()
+}
+
+// editorWidth is the width available to the editor pane, half the screen when the live preview is open.
+func (m model) editorWidth() int {
+	if m.editorPreview {
+		return m.width / 2
+	}
+	return m.width
+}
+
+// editorBodyHeight is the number of text rows below the header and above the status line and search bar.
+func (m model) editorBodyHeight() int {
+	h := m.height - 5
+	if m.search.active {
+		h -= 2
+	}
+	return max(1, h)
+}
+
+// editorSpans collects the styled ranges for a line.
+func (m model) editorSpans(row int, line string) []span {
+	spans := m.visualSpans(row, line)
+	return append(spans, m.searchSpans(row)...)
+}
+
+// NOTE: renderEditor draws the visible part of the buffer with line numbers, syntax highlighting, spelling, styled spans and the cursor.
+func (m model) renderEditor() string {
+	height := m.editorBodyHeight()
+	width := max(1, m.editorTextWidth())
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	cursorRow, cursorCol := m.editorCursor()
+
+	prompt := lipgloss.NewStyle().Foreground(m.theme.Border)
+	number := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	cursorNumber := lipgloss.NewStyle().Foreground(m.theme.Accent)
+	text := lipgloss.NewStyle().Foreground(m.theme.Text)
+	cursor := lipgloss.NewStyle().Reverse(true)
+	digits := m.editorNumberWidth()
+
+	highlight, misspelled := m.cachedSpans(lines)
+
+	var out []string
+	for row := m.editorTop; row < len(lines) && len(out) < height; row++ {
+		runes := []rune(lines[row])
+		spans := append(append(highlight[row], misspelled[row]...), m.editorSpans(row, lines[row])...)
+		if row == cursorRow {
+			spans = append(spans, span{start: cursorCol, end: cursorCol + 1, style: cursor})
+		}
+
+		starts := wrapRunes(runes, width)
+		for i, start := range starts {
+			if len(out) >= height {
+				break
+			}
+			end := len(runes)
+			if i+1 < len(starts) {
+				end = starts[i+1]
+			}
+
+			gutter := prompt.Render("┃ ") + strings.Repeat(" ", digits+1)
+			if i == 0 {
+				num := number
+				if row == cursorRow {
+					num = cursorNumber
+				}
+				gutter = prompt.Render("┃ ") + num.Render(fmt.Sprintf("%*d ", digits, row+1))
+			}
+
+			segment := renderSpans(runes, start, end, spans, text)
+			// The cursor may sit one past the end of the line
+			if row == cursorRow && cursorCol == end && (i+1 == len(starts)) {
+				segment += cursor.Render(" ")
+			}
+			out = append(out, gutter+segment)
+		}
+	}
+
+	for len(out) < height {
+		out = append(out, prompt.Render("┃ ")+number.Render(fmt.Sprintf("%*s ", digits, "~")))
+	}
+	return strings.Join(out, "\n")
+}
+
+// renderSpans renders runes[start:end], grouping runs that share the same set of spans into one styled segment.
+func renderSpans(runes []rune, start, end int, spans []span, base lipgloss.Style) string {
+	var b strings.Builder
+	segStart := start
+	active := func(i int) []int {
+		var ids []int
+		for j, s := range spans {
+			if i >= s.start && i < s.end {
+				ids = append(ids, j)
+			}
+		}
+		return ids
+	}
+	same := func(a, b []int) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	flush := func(from, to int, ids []int) {
+		if from >= to {
+			return
+		}
+		style := base
+		for _, id := range ids {
+			style = spans[id].style.Inherit(style)
+		}
+		b.WriteString(style.Render(string(runes[from:to])))
+	}
+
+	var current []int
+	if start < end {
+		current = active(start)
+	}
+	for i := start; i < end; i++ {
+		ids := active(i)
+		if !same(ids, current) {
+			flush(segStart, i, current)
+			segStart = i
+			current = ids
+		}
+	}
+	flush(segStart, end, current)
+	return b.String()
+}
@@ -3,7 +3,6 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -15,11 +14,9 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/alecthomas/chroma/v2/quick"
 	"github.com/charmbracelet/bubbles/list"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
-	"github.com/charmbracelet/glamour/styles"
 )
 
 var (
@@ -28,12 +25,8 @@ var (
 )
 
 func init() {
-	style := styles.DarkStyleConfig
-	margin := uint(0)
-	style.Document.Margin = &margin
-
 	glamourRenderer, _ = glamour.NewTermRenderer(
-		glamour.WithStyles(style),
+		glamour.WithStyles(glamourStyle(themes["default"])),
 		glamour.WithWordWrap(0),
 	)
 }
@@ -48,13 +41,25 @@ func renderMarkdown(content string) string {
 	return rendered
 }
 
+// previewOptions are the display settings a text preview is rendered with.
+type previewOptions struct {
+	width, height int
+	theme         Theme
+	lineNumbers   bool
+	// treeDepth is how many levels of a JSON or YAML tree are expanded.
+	treeDepth int
+}
+
 /*
 	NOTE:
 
-readFile loads text file content with syntax highlighting.
-Images are NOT handled here — they use renderImage() instead.
+readFile loads text file content with syntax highlighting in the theme's
+colors, detecting the language from the file name or its content.
+CSV, TSV, JSON and YAML files are shown as tables and trees instead.
+Images are NOT handled here — they use renderImage() instead, but images
+embedded in markdown are drawn inline within width x height.
 */
-func readFile(path string) tea.Cmd {
+func readFile(path string, opts previewOptions) tea.Cmd {
 	return func() tea.Msg {
 		content, err := os.ReadFile(path)
 		if err != nil {
@@ -63,7 +68,7 @@ func readFile(path string) tea.Cmd {
 
 		ext := strings.ToLower(filepath.Ext(path))
 		switch ext {
-		case ".md", ".markdown", ".txt", ".go", ".c", ".cpp", ".h", ".py", ".js", ".ts", ".html", ".css", ".json", ".yaml", ".yml", ".toml", ".sh", ".mod", ".sum":
+		case ".md", ".markdown", ".txt", ".csv", ".tsv", ".go", ".c", ".cpp", ".h", ".py", ".js", ".ts", ".html", ".css", ".json", ".yaml", ".yml", ".toml", ".sh", ".mod", ".sum":
 		default:
 			buffer := make([]byte, 512)
 			copy(buffer, content)
@@ -75,16 +80,30 @@ func readFile(path string) tea.Cmd {
 			}
 		}
 
+		if msg, ok := structuredPreview(ext, string(content), opts); ok {
+			return msg
+		}
+
+		// Only notes have an outline; "#" starts a comment in .py, .sh, .toml and the like
+		var headings []heading
+		switch ext {
+		case ".md", ".markdown", ".org", ".txt":
+			headings = parseHeadings(path, string(content))
+		}
 		if ext == ".md" || ext == ".markdown" {
-			return fileLoadedMsg{content: renderMarkdown(string(content))}
+			return fileLoadedMsg{content: renderNote(path, string(content), opts.width, opts.height), headings: headings, language: "Markdown"}
 		}
 
-		var buf bytes.Buffer
-		err = quick.Highlight(&buf, string(content), "markdown", "terminal256", "monokai")
-		if err != nil {
-			return fileLoadedMsg{content: string(content)}
+		lexer := previewLexer(path, string(content))
+		language := lexer.Config().Name
+		if language == "fallback" || language == "plaintext" {
+			language = "Plain text"
+		}
+		return fileLoadedMsg{
+			content:  highlightCode(lexer, string(content), opts.theme, opts.lineNumbers),
+			headings: headings,
+			language: language,
 		}
-		return fileLoadedMsg{content: buf.String()}
 	}
 }
 
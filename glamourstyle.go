@@ -0,0 +1,95 @@
+// NOTE: Glamour styles for the markdown preview, derived from the active Theme. A JSON file in <vault>/.styles/<theme>.json overrides any part of them
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/glamour"
+	"github.com/charmbracelet/glamour/ansi"
+	"github.com/charmbracelet/glamour/styles"
+	"github.com/charmbracelet/lipgloss"
+)
+
+func colorPtr(c lipgloss.Color) *string {
+	s := string(c)
+	return &s
+}
+
+// glamourStyle recolors glamour's dark (or light) style with the theme's colors.
+func glamourStyle(t Theme) ansi.StyleConfig {
+	style := styles.DarkStyleConfig
+	heading, codeBg := lipgloss.Color("230"), lipgloss.Color("236")
+	if t.Light {
+		style = styles.LightStyleConfig
+		heading, codeBg = lipgloss.Color("255"), lipgloss.Color("254")
+	}
+
+	margin := uint(0)
+	style.Document.Margin = &margin
+	style.Document.Color = colorPtr(t.Text)
+
+	style.Heading.Color = colorPtr(t.Primary)
+	style.H1.Color = colorPtr(heading)
+	style.H1.BackgroundColor = colorPtr(t.Primary)
+	style.H6.Color = colorPtr(t.SubText)
+
+	style.Link.Color = colorPtr(t.Muted)
+	style.LinkText.Color = colorPtr(t.Accent)
+	style.Image.Color = colorPtr(t.Accent)
+	style.ImageText.Color = colorPtr(t.SubText)
+
+	style.Code.Color = colorPtr(t.Accent)
+	style.Code.BackgroundColor = colorPtr(codeBg)
+	style.CodeBlock.Color = colorPtr(t.SubText)
+
+	style.BlockQuote.Color = colorPtr(t.SubText)
+	style.HorizontalRule.Color = colorPtr(t.Border)
+	style.Item.Color = colorPtr(t.Primary)
+	style.Enumeration.Color = colorPtr(t.Primary)
+	style.Task.Color = colorPtr(t.Accent)
+	return style
+}
+
+// userStylePath is the optional glamour JSON style for a theme.
+func userStylePath(themeName string) string {
+	return filepath.Join(vaultDir, ".styles", themeName+".json")
+}
+
+// NOTE: setGlamourTheme rebuilds the markdown renderer for a theme. If the user style file is invalid the derived style
+// is still applied and the error is returned so it can be reported.
+func setGlamourTheme(themeName string, t Theme) error {
+	style := glamourStyle(t)
+	var styleErr error
+	if data, err := os.ReadFile(userStylePath(themeName)); err == nil {
+		// Round-trip through JSON so overrides don't write through pointers shared with glamour's built-in styles
+		var merged ansi.StyleConfig
+		base, err := json.Marshal(style)
+		if err == nil {
+			err = json.Unmarshal(base, &merged)
+		}
+		if err == nil {
+			err = json.Unmarshal(data, &merged)
+		}
+		if err != nil {
+			styleErr = fmt.Errorf("%s: %w", userStylePath(themeName), err)
+		} else {
+			style = merged
+		}
+	}
+
+	renderer, err := glamour.NewTermRenderer(
+		glamour.WithStyles(style),
+		glamour.WithWordWrap(0),
+	)
+	if err != nil {
+		return err
+	}
+	glamourMu.Lock()
+	glamourRenderer = renderer
+	glamourMu.Unlock()
+	return styleErr
+}
@@ -0,0 +1,326 @@
+// NOTE: Terminal graphics detection and the image backends: Sixel and iTerm2 draw over the preview, Kitty, half-block and braille render as text
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+type graphicsMode int
+
+const (
+	graphicsNone graphicsMode = iota
+	graphicsHalfBlock
+	graphicsBraille
+	graphicsSixel
+	graphicsITerm2
+	graphicsKitty
+)
+
+var graphicsNames = map[graphicsMode]string{
+	graphicsNone:      "none",
+	graphicsHalfBlock: "halfblock",
+	graphicsBraille:   "braille",
+	graphicsSixel:     "sixel",
+	graphicsITerm2:    "iterm2",
+	graphicsKitty:     "kitty",
+}
+
+func (g graphicsMode) String() string {
+	return graphicsNames[g]
+}
+
+// overlay reports whether the backend draws pixels over the preview at screen coordinates instead of returning text.
+// Kitty images are text too: placeholder cells the terminal fills in.
+func (g graphicsMode) overlay() bool {
+	return g == graphicsSixel || g == graphicsITerm2
+}
+
+// graphics is the image backend in use, chosen by detectGraphics at startup.
+var graphics = graphicsHalfBlock
+
+// NOTE: detectGraphics picks the image backend: the "graphics" config value if set, otherwise a guess from the
+// environment variables terminals are known to set. Terminals that can't be recognised get the half-block renderer,
+// and so does tmux when its allow-passthrough option is off, as no overlay can reach the terminal then.
+func detectGraphics() (graphicsMode, error) {
+	term, program := os.Getenv("TERM"), os.Getenv("TERM_PROGRAM")
+	passthrough := true
+	if inTmux {
+		// tmux sets TERM and TERM_PROGRAM for itself, ask it for the terminal it is attached to
+		term, passthrough = tmuxClient()
+		program = ""
+	}
+
+	mode, err := guessGraphics(term, program)
+	if err == nil && (mode.overlay() || mode == graphicsKitty) && !passthrough {
+		mode = graphicsHalfBlock
+	}
+	return mode, err
+}
+
+func guessGraphics(term, program string) (graphicsMode, error) {
+	if name := strings.ToLower(cfg.Graphics); name != "" && name != "auto" {
+		for mode, n := range graphicsNames {
+			if n == name {
+				return mode, nil
+			}
+		}
+		return graphicsNone, fmt.Errorf("unknown graphics mode %q (want auto, kitty, sixel, iterm2, halfblock, braille or none)", cfg.Graphics)
+	}
+
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "" || term == "xterm-kitty" || term == "xterm-ghostty" || program == "ghostty":
+		return graphicsKitty, nil
+	// WezTerm speaks the Kitty protocol but not its Unicode placeholders, which previews rely on
+	case program == "iTerm.app" || program == "WezTerm" || os.Getenv("LC_TERMINAL") == "iTerm2":
+		return graphicsITerm2, nil
+	case strings.HasPrefix(term, "foot") || strings.Contains(term, "mlterm") || strings.HasPrefix(term, "contour") ||
+		program == "mintty" || os.Getenv("WT_SESSION") != "":
+		return graphicsSixel, nil
+	case term == "dumb" || term == "linux":
+		return graphicsNone, nil
+	}
+	return graphicsHalfBlock, nil
+}
+
+// resize scales an image to exactly w x h: averaging when shrinking, nearest neighbour when growing.
+func resize(src image.Image, w, h int) image.Image {
+	b := src.Bounds()
+	if w <= b.Dx() && h <= b.Dy() {
+		if w == b.Dx() && h == b.Dy() {
+			return src
+		}
+		return scaleDown(src, w, h)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dst.Set(x, y, src.At(b.Min.X+x*b.Dx()/w, b.Min.Y+y*b.Dy()/h))
+		}
+	}
+	return dst
+}
+
+var (
+	overlayMu    sync.Mutex
+	overlayDirty bool
+)
+
+// clearGraphics removes any image shown in the preview. Kitty images are deleted by id to free the terminal's memory;
+// Sixel and iTerm2 pixels can only be removed by repainting the screen.
+func clearGraphics() tea.Cmd {
+	return func() tea.Msg {
+		writeGraphics(kittyDeleteShown())
+		overlayMu.Lock()
+		dirty := overlayDirty
+		overlayDirty = false
+		overlayMu.Unlock()
+		if dirty {
+			return tea.ClearScreen()
+		}
+		return nil
+	}
+}
+
+// encodeOverlay returns the escapes that draw an image at the cursor with the current overlay backend.
+func encodeOverlay(path string, cols, rows int) ([]byte, error) {
+	img, err := decodeImage(path)
+	if err != nil {
+		return nil, err
+	}
+	cellW, cellH := cellSize()
+	b := img.Bounds()
+	fitCols, fitRows, pxW, pxH := fitCells(b.Dx(), b.Dy(), cols, rows, cellW, cellH)
+	img = resize(img, pxW, pxH)
+
+	if graphics == graphicsSixel {
+		return encodeSixel(img), nil
+	}
+	payload, err := pngBase64(img)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("\x1b]1337;File=inline=1;width=%d;height=%d;preserveAspectRatio=1:%s\a", fitCols, fitRows, payload)), nil
+}
+
+// encodeSixel quantises an image to a 256 color palette and encodes it as Sixel. Mostly transparent pixels are left unpainted.
+func encodeSixel(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	pal := image.NewPaletted(image.Rect(0, 0, w, h), palette.Plan9)
+	draw.FloydSteinberg.Draw(pal, pal.Bounds(), img, b.Min)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "\x1bPq\"1;1;%d;%d", w, h)
+	for i, c := range pal.Palette {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&buf, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+
+	opaque := func(x, y int) bool {
+		_, _, _, a := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+		return a >= 0x8000
+	}
+	for band := 0; band < h; band += 6 {
+		// Each color present in the band is painted in its own pass over the row
+		used := map[uint8]bool{}
+		for y := band; y < min(h, band+6); y++ {
+			for x := 0; x < w; x++ {
+				if opaque(x, y) {
+					used[pal.ColorIndexAt(x, y)] = true
+				}
+			}
+		}
+		for idx := range used {
+			fmt.Fprintf(&buf, "#%d", idx)
+			var run byte
+			count := 0
+			flush := func() {
+				switch {
+				case count > 3:
+					fmt.Fprintf(&buf, "!%d%c", count, run)
+				case count > 0:
+					buf.Write(bytes.Repeat([]byte{run}, count))
+				}
+			}
+			for x := 0; x < w; x++ {
+				bits := 0
+				for k := 0; k < 6 && band+k < h; k++ {
+					if pal.ColorIndexAt(x, band+k) == idx && opaque(x, band+k) {
+						bits |= 1 << k
+					}
+				}
+				ch := byte(63 + bits)
+				if ch != run {
+					flush()
+					run, count = ch, 0
+				}
+				count++
+			}
+			flush()
+			buf.WriteByte('$')
+		}
+		buf.WriteByte('-')
+	}
+	buf.WriteString("\x1b\\")
+	return buf.Bytes()
+}
+
+// ansiColor returns the SGR parameters for a color in the terminal's color profile.
+func ansiColor(c color.Color, background bool) string {
+	r, g, b, _ := c.RGBA()
+	hex := fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+	return lipgloss.ColorProfile().Color(hex).Sequence(background)
+}
+
+// NOTE: halfBlockImage draws two pixels per cell with "▀": the top pixel as the foreground and the bottom one as the background.
+func halfBlockImage(img image.Image, cols, rows int) string {
+	img = resize(img, cols, rows*2)
+	var out strings.Builder
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			top, bottom := img.At(x, y*2), img.At(x, y*2+1)
+			fmt.Fprintf(&out, "%s%s;%sm▀", termenv.CSI, ansiColor(top, false), ansiColor(bottom, true))
+		}
+		out.WriteString(termenv.CSI + termenv.ResetSeq + "m\n")
+	}
+	return out.String()
+}
+
+// brailleDots maps a pixel offset within a 2x4 cell to its braille dot bit.
+var brailleDots = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+func luminance(c color.Color) float64 {
+	r, g, b, _ := c.RGBA()
+	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+}
+
+// NOTE: brailleImage draws 2x4 dots per cell, lighting the pixels brighter than the image's average and coloring each cell by its lit pixels.
+func brailleImage(img image.Image, cols, rows int) string {
+	img = resize(img, cols*2, rows*4)
+	var total float64
+	for y := 0; y < rows*4; y++ {
+		for x := 0; x < cols*2; x++ {
+			total += luminance(img.At(x, y))
+		}
+	}
+	threshold := total / float64(cols*2*rows*4)
+
+	var out strings.Builder
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			dots := rune(0)
+			var r, g, b, n uint32
+			for dy := 0; dy < 4; dy++ {
+				for dx := 0; dx < 2; dx++ {
+					c := img.At(x*2+dx, y*4+dy)
+					if luminance(c) > threshold {
+						dots |= brailleDots[dy][dx]
+						cr, cg, cb, _ := c.RGBA()
+						r, g, b, n = r+cr, g+cg, b+cb, n+1
+					}
+				}
+			}
+			if n == 0 {
+				out.WriteRune(' ')
+				continue
+			}
+			avg := color.RGBA64{uint16(r / n), uint16(g / n), uint16(b / n), 0xffff}
+			fmt.Fprintf(&out, "%s%sm%c", termenv.CSI, ansiColor(avg, false), 0x2800+dots)
+		}
+		out.WriteString(termenv.CSI + termenv.ResetSeq + "m\n")
+	}
+	return out.String()
+}
+
+// NOTE: renderImageText renders an image as text for the half-block, braille and none backends. The result goes through
+// the viewport like any other preview.
+func renderImageText(path string, cols, rows int) tea.Cmd {
+	return func() tea.Msg {
+		if graphics == graphicsNone {
+			f, err := os.Open(path)
+			if err != nil {
+				return fileLoadedMsg{content: "Cannot preview image: " + err.Error()}
+			}
+			defer f.Close()
+			conf, _, err := image.DecodeConfig(f)
+			if err != nil {
+				return fileLoadedMsg{content: "Cannot preview image: " + err.Error()}
+			}
+			return fileLoadedMsg{content: fmt.Sprintf("[Image: %s, %dx%d]", filepath.Base(path), conf.Width, conf.Height)}
+		}
+		r, err := cachedImage(graphics, path, cols, rows)
+		if err != nil {
+			return fileLoadedMsg{content: "Cannot preview image: " + err.Error()}
+		}
+		return fileLoadedMsg{content: string(r.data)}
+	}
+}
+
+// imageText draws an image with the half-block or braille renderer, scaled to fit cols x rows.
+func imageText(mode graphicsMode, path string, cols, rows int) (imageRender, error) {
+	img, err := decodeImage(path)
+	if err != nil {
+		return imageRender{}, err
+	}
+	// Half-block cells hold 1x2 pixels and braille cells 2x4, both roughly square for a 1:2 cell
+	b := img.Bounds()
+	fitCols, fitRows, _, _ := fitCells(b.Dx(), b.Dy(), cols, rows, 1, 2)
+	if mode == graphicsBraille {
+		return imageRender{data: []byte(brailleImage(img, fitCols, fitRows)), cols: fitCols, rows: fitRows}, nil
+	}
+	return imageRender{data: []byte(halfBlockImage(img, fitCols, fitRows)), cols: fitCols, rows: fitRows}, nil
+}
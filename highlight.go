@@ -0,0 +1,117 @@
+// NOTE: Syntax highlighting for the inbuilt editor and code previews, using chroma's lexers with colors from the active Theme
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// editorLexer picks a lexer from the file name, falling back to plain text.
+func editorLexer(path string) chroma.Lexer {
+	lexer := lexers.Match(filepath.Base(path))
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	return chroma.Coalesce(lexer)
+}
+
+// tokenStyle maps a chroma token type to a Theme color. ok is false for tokens drawn as plain text.
+func (th Theme) tokenStyle(t chroma.TokenType) (lipgloss.Style, bool) {
+	s := lipgloss.NewStyle()
+	switch {
+	case t == chroma.GenericHeading || t == chroma.GenericSubheading:
+		return s.Foreground(th.Primary).Bold(true), true
+	case t == chroma.GenericEmph:
+		return s.Italic(true), true
+	case t == chroma.GenericStrong:
+		return s.Bold(true), true
+	case t == chroma.GenericDeleted:
+		return s.Strikethrough(true).Foreground(th.Muted), true
+	case t == chroma.NameTag:
+		// Link text
+		return s.Foreground(th.Accent).Underline(true), true
+	case t == chroma.NameAttribute:
+		// Link target
+		return s.Foreground(th.Muted), true
+	case t == chroma.NameEntity:
+		// #tags and @mentions
+		return s.Foreground(th.Accent), true
+	case t.InCategory(chroma.Comment):
+		return s.Foreground(th.Muted).Italic(true), true
+	case t.InCategory(chroma.Keyword):
+		return s.Foreground(th.Accent), true
+	case t.InCategory(chroma.LiteralString), t.InCategory(chroma.LiteralNumber):
+		return s.Foreground(th.Secondary), true
+	case t.InSubCategory(chroma.NameFunction), t.InSubCategory(chroma.NameClass):
+		return s.Foreground(th.Primary), true
+	}
+	return s, false
+}
+
+// spanCache keeps the editor's syntax and spelling spans for one revision of the buffer. The model holds it by
+// pointer so View, which works on a copy, can fill it in.
+type spanCache struct {
+	rev        int
+	theme      string
+	highlight  [][]span
+	misspelled [][]span
+}
+
+// NOTE: cachedSpans returns the syntax and spelling spans for the buffer, tokenising and checking again only when the
+// text or theme changed rather than on every keypress and cursor blink.
+func (m model) cachedSpans(lines []string) (highlight, misspelled [][]span) {
+	c := m.spanCache
+	if c.rev != m.editorRev || c.theme != m.themeName || len(c.highlight) != len(lines) {
+		*c = spanCache{rev: m.editorRev, theme: m.themeName, highlight: m.highlightLines(lines), misspelled: m.spellSpans(lines)}
+	}
+	return c.highlight, c.misspelled
+}
+
+// NOTE: highlightLines tokenises the whole buffer (so code fences and block comments span lines) and returns the spans for each line.
+func (m model) highlightLines(lines []string) [][]span {
+	out := make([][]span, len(lines))
+	lexer := editorLexer(m.editorFile)
+	markdown := lexer.Config().Name == "markdown"
+
+	// Fenced code blocks are colored as a whole; the fence's own lexer adds detail on top
+	if markdown {
+		code := lipgloss.NewStyle().Foreground(m.theme.Secondary)
+		inFence := false
+		for row, line := range lines {
+			fence := strings.HasPrefix(strings.TrimSpace(line), "```")
+			if inFence || fence {
+				out[row] = append(out[row], span{start: 0, end: utf8.RuneCountInString(line), style: code})
+			}
+			if fence {
+				inFence = !inFence
+			}
+		}
+	}
+
+	it, err := lexer.Tokenise(nil, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		return out
+	}
+	row, col := 0, 0
+	for tok := it(); tok != chroma.EOF; tok = it() {
+		style, ok := m.theme.tokenStyle(tok.Type)
+		for i, part := range strings.Split(tok.Value, "\n") {
+			if i > 0 {
+				row++
+				col = 0
+			}
+			n := utf8.RuneCountInString(part)
+			if ok && n > 0 && row < len(out) {
+				out[row] = append(out[row], span{start: col, end: col + n, style: style})
+			}
+			col += n
+		}
+	}
+	return out
+}
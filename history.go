@@ -0,0 +1,142 @@
+// NOTE: Undo/redo history for the inbuilt editor
+
+package main
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// editGroupTimeout ends the current undo group after a pause in typing.
+const editGroupTimeout = time.Second
+
+type editKind int
+
+const (
+	editNone editKind = iota
+	editTyping
+	editDelete
+	editPaste
+	editOther
+)
+
+// editorSnapshot is the buffer and cursor position before an undo group.
+type editorSnapshot struct {
+	value string
+	row   int
+	col   int
+}
+
+// editHistory keeps undo and redo stacks for one editing session. Consecutive
+// edits of the same kind are grouped so that a typed word or a run of
+// backspaces undoes in one step; pastes and other edits are always their own
+// group.
+type editHistory struct {
+	undo     []editorSnapshot
+	redo     []editorSnapshot
+	lastKind editKind
+	lastEdit time.Time
+}
+
+// classifyEdit decides which undo group a message belongs to and whether it
+// must start a new group regardless of the previous edit.
+func classifyEdit(msg tea.Msg, before string, col int, row int) (editKind, bool) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		// Clipboard paste results from ctrl+v
+		return editPaste, true
+	}
+	if key.Paste {
+		return editPaste, true
+	}
+	switch key.Type {
+	case tea.KeyRunes, tea.KeySpace:
+		if len(key.Runes) != 1 {
+			return editPaste, true
+		}
+		// A word boundary starts a new group: "hello world" undoes word by word
+		return editTyping, unicode.IsSpace(key.Runes[0]) && !precededBySpace(before, row, col)
+	case tea.KeyEnter:
+		return editTyping, true
+	case tea.KeyBackspace, tea.KeyDelete, tea.KeyCtrlW, tea.KeyCtrlK, tea.KeyCtrlU, tea.KeyCtrlH, tea.KeyCtrlD:
+		return editDelete, false
+	default:
+		return editOther, true
+	}
+}
+
+func precededBySpace(value string, row, col int) bool {
+	line := []rune(lineAt(value, row))
+	if col <= 0 || col > len(line) {
+		return true
+	}
+	return unicode.IsSpace(line[col-1])
+}
+
+// record registers a change. before is the state prior to the change and is
+// pushed as a new undo point when the change starts a new group.
+func (h *editHistory) record(before editorSnapshot, kind editKind, forceBreak bool, now time.Time) {
+	if forceBreak || kind != h.lastKind || now.Sub(h.lastEdit) > editGroupTimeout {
+		h.undo = append(h.undo, before)
+	}
+	h.redo = nil
+	h.lastKind = kind
+	h.lastEdit = now
+}
+
+// step pops a snapshot from one stack, pushes current onto the other and
+// returns the snapshot to restore.
+func (h *editHistory) step(from, to *[]editorSnapshot, current editorSnapshot) (editorSnapshot, bool) {
+	if len(*from) == 0 {
+		return editorSnapshot{}, false
+	}
+	snap := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	*to = append(*to, current)
+	h.lastKind = editNone
+	return snap, true
+}
+
+func (h *editHistory) undoStep(current editorSnapshot) (editorSnapshot, bool) {
+	return h.step(&h.undo, &h.redo, current)
+}
+
+func (h *editHistory) redoStep(current editorSnapshot) (editorSnapshot, bool) {
+	return h.step(&h.redo, &h.undo, current)
+}
+
+// snapshotEditor captures the textarea's text and logical cursor position.
+func snapshotEditor(ta textarea.Model) editorSnapshot {
+	li := ta.LineInfo()
+	return editorSnapshot{value: ta.Value(), row: ta.Line(), col: li.StartColumn + li.ColumnOffset}
+}
+
+// NOTE: restoreSnapshot replaces the buffer with an undo/redo snapshot and restarts the swap/autosave timers.
+func (m model) restoreSnapshot(snap editorSnapshot) (model, tea.Cmd) {
+	m.editorContent.SetValue(snap.value)
+	setEditorCursor(&m.editorContent, snap.row, snap.col)
+	m.editorRev++
+	return m.followCursor(), scheduleEditorTicks(m.editorRev)
+}
+
+// NOTE: undoEdit and redoEdit walk the history, reporting in the header when there is nothing left.
+func (m model) undoEdit() (tea.Model, tea.Cmd) {
+	snap, ok := m.editorHistory.undoStep(snapshotEditor(m.editorContent))
+	if !ok {
+		m.editorStatus = "Nothing to undo"
+		return m, nil
+	}
+	return m.restoreSnapshot(snap)
+}
+
+func (m model) redoEdit() (tea.Model, tea.Cmd) {
+	snap, ok := m.editorHistory.redoStep(snapshotEditor(m.editorContent))
+	if !ok {
+		m.editorStatus = "Nothing to redo"
+		return m, nil
+	}
+	return m.restoreSnapshot(snap)
+}
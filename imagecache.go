@@ -0,0 +1,147 @@
+// NOTE: Least recently used cache of encoded images, bounded by memory and keyed by file stamp and size, plus neighbour prerendering
+
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultImageCacheMB is the cache size used when image_cache_mb isn't set.
+const defaultImageCacheMB = 64
+
+// imageRender is an image encoded for the current backend: escapes for Kitty, Sixel and iTerm2, text for half-block
+// and braille. cols and rows are the cells it takes up; key names the file version and size it was made from.
+type imageRender struct {
+	data       []byte
+	cols, rows int
+	key        string
+}
+
+// imageCacheEntry is one render. stamp identifies the version of the file it was made from.
+type imageCacheEntry struct {
+	key, path, stamp string
+	render           imageRender
+}
+
+var (
+	imageCacheMu    sync.Mutex
+	imageCacheList  = list.New()
+	imageCacheIndex = map[string]*list.Element{}
+	imageCacheBytes int
+)
+
+func imageCacheLimit() int {
+	if cfg.ImageCacheMB > 0 {
+		return cfg.ImageCacheMB << 20
+	}
+	return defaultImageCacheMB << 20
+}
+
+func (e *imageCacheEntry) size() int {
+	return len(e.key) + len(e.render.data)
+}
+
+// removeImageCacheEntry drops an entry. The caller holds imageCacheMu.
+func removeImageCacheEntry(el *list.Element) {
+	e := imageCacheList.Remove(el).(*imageCacheEntry)
+	delete(imageCacheIndex, e.key)
+	imageCacheBytes -= e.size()
+}
+
+// NOTE: cachedImage returns the image at path rendered with a backend into cols x rows cells, encoding it on a miss. The key includes
+// the file's modification time and size, so an edited image is re-encoded and its stale renders are dropped.
+func cachedImage(mode graphicsMode, path string, cols, rows int) (imageRender, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return imageRender{}, err
+	}
+	stamp := fmt.Sprintf("%d|%d", info.ModTime().UnixNano(), info.Size())
+	key := fmt.Sprintf("%s|%s|%s|%dx%d", path, stamp, mode, cols, rows)
+
+	imageCacheMu.Lock()
+	if el, ok := imageCacheIndex[key]; ok {
+		imageCacheList.MoveToFront(el)
+		r := el.Value.(*imageCacheEntry).render
+		imageCacheMu.Unlock()
+		return r, nil
+	}
+	imageCacheMu.Unlock()
+
+	r, err := encodeImage(mode, path, cols, rows)
+	if err != nil {
+		return imageRender{}, err
+	}
+	r.key = key
+
+	imageCacheMu.Lock()
+	defer imageCacheMu.Unlock()
+	for el := imageCacheList.Front(); el != nil; {
+		next := el.Next()
+		if e := el.Value.(*imageCacheEntry); e.key == key || (e.path == path && e.stamp != stamp) {
+			removeImageCacheEntry(el)
+		}
+		el = next
+	}
+	e := &imageCacheEntry{key: key, path: path, stamp: stamp, render: r}
+	if e.size() > imageCacheLimit() {
+		return r, nil
+	}
+	imageCacheIndex[key] = imageCacheList.PushFront(e)
+	imageCacheBytes += e.size()
+	for imageCacheBytes > imageCacheLimit() {
+		removeImageCacheEntry(imageCacheList.Back())
+	}
+	return r, nil
+}
+
+// encodeImage renders an image with a backend, without the cache.
+func encodeImage(mode graphicsMode, path string, cols, rows int) (imageRender, error) {
+	switch mode {
+	case graphicsKitty:
+		data, err := encodeKitty(path, kittyImageID(path), cols, rows)
+		if err != nil {
+			return imageRender{}, err
+		}
+		fitCols, fitRows, err := kittyFit(path, cols, rows)
+		return imageRender{data: data, cols: fitCols, rows: fitRows}, err
+	case graphicsSixel, graphicsITerm2:
+		data, err := encodeOverlay(path, cols, rows)
+		return imageRender{data: data, cols: cols, rows: rows}, err
+	}
+	return imageText(mode, path, cols, rows)
+}
+
+// NOTE: prerenderNeighbours encodes the images next to the selected list item in the background, so moving through a
+// folder of attachments finds them already in the cache.
+func (m model) prerenderNeighbours(cols, rows int) tea.Cmd {
+	if graphics == graphicsNone {
+		return nil
+	}
+	items := m.list.VisibleItems()
+	index := m.list.Index()
+	var paths []string
+	for _, i := range []int{index + 1, index - 1} {
+		if i < 0 || i >= len(items) {
+			continue
+		}
+		if it, ok := items[i].(item); ok {
+			if path := m.resolveFilePath(it.title); isImageFile(path) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return func() tea.Msg {
+		for _, path := range paths {
+			cachedImage(graphics, path, cols, rows)
+		}
+		return nil
+	}
+}
@@ -14,7 +14,6 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
-	"sync"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -41,14 +40,6 @@ func isImageFile(path string) bool {
 	return strings.HasPrefix(ct, "image/")
 }
 
-// clearKittyGraphics sends the escape sequence to delete all Kitty images.
-func clearKittyGraphics() tea.Cmd {
-	return func() tea.Msg {
-		fmt.Print("\x1b_Ga=d,d=a\x1b\\")
-		return nil
-	}
-}
-
 // HACK: Getting ascpect ratio helps me to draw 9:16,4:5 images away from list, with better height. Without this it looked like they were stuck next to each other
 func getImageAspectRatio(path string) (float64, error) {
 	f, err := os.Open(path)
@@ -71,54 +62,52 @@ func getImageAspectRatio(path string) (float64, error) {
 /*
 	NOTE:
 
-renderImage uses chafa to generate a Kitty image escape sequence, then
-writes it directly to stdout at a specific cell offset. The output is
-captured first to prevent chafa's own cursor movements from wrecking
-the Bubble Tea TUI.
+renderImage encodes the image for the overlay backend (Sixel or
+iTerm2) and writes it directly to stdout at a specific cell offset, saving
+and restoring the cursor around it so the Bubble Tea TUI is left alone.
+Inside tmux the whole sequence is passed through to the outer terminal.
 */
-var (
-	imageCache   = map[string][]byte{}
-	imageCacheMu sync.Mutex
-)
-
 func renderImage(path string, cols, rows, xOffset, yOffset int) tea.Cmd {
 	return func() tea.Msg {
-		key := fmt.Sprintf("%s-%dx%d", path, cols, rows)
-
-		imageCacheMu.Lock()
-		cached, ok := imageCache[key]
-		imageCacheMu.Unlock()
-
-		if ok {
-			var buf bytes.Buffer
-			buf.WriteString("\x1b[s")
-			buf.WriteString(fmt.Sprintf("\x1b[%d;%dH", yOffset, xOffset))
-			buf.Write(cached)
-			buf.WriteString("\x1b[u")
-			os.Stdout.Write(buf.Bytes())
-			return imageRenderedMsg{}
-		}
-
-		cmd := exec.Command("chafa", "-f", "kitty", "-s", fmt.Sprintf("%dx%d", cols, rows), path)
-		output, err := cmd.Output()
+		r, err := cachedImage(graphics, path, cols, rows)
 		if err != nil {
-			return imageRenderedMsg{}
+			return imageRenderedMsg{err: err}
 		}
 
-		imageCacheMu.Lock()
-		imageCache[key] = output
-		imageCacheMu.Unlock()
+		// Inside tmux the cursor move reaches the outer terminal too, so it is relative to the whole window
+		if inTmux {
+			top, left := tmuxPaneOffset()
+			xOffset += left
+			yOffset += top
+		}
 
 		var buf bytes.Buffer
 		buf.WriteString("\x1b[s")
 		buf.WriteString(fmt.Sprintf("\x1b[%d;%dH", yOffset, xOffset))
-		buf.Write(output)
+		buf.Write(r.data)
 		buf.WriteString("\x1b[u")
-		os.Stdout.Write(buf.Bytes())
+		writeGraphics(buf.Bytes())
+		overlayMu.Lock()
+		overlayDirty = true
+		overlayMu.Unlock()
 		return imageRenderedMsg{}
 	}
 }
 
+// NOTE: renderKittyImage transmits an image to Kitty and returns its placeholder cells as the preview text, so the image
+// moves with the layout instead of being pinned to screen coordinates.
+func renderKittyImage(path string, cols, rows int) tea.Cmd {
+	return func() tea.Msg {
+		r, err := cachedImage(graphics, path, cols, rows)
+		if err != nil {
+			return fileLoadedMsg{content: "Cannot preview image: " + err.Error()}
+		}
+		id := kittyImageID(path)
+		sendKittyImage(id, r)
+		return fileLoadedMsg{content: kittyPlaceholders(id, r.cols, r.rows)}
+	}
+}
+
 func openImageViewer(path string) tea.Cmd {
 	viewer := cmp.Or(os.Getenv("IMAGE_VIEWER"), "xdg-open")
 	c := exec.Command(viewer, path)
@@ -11,6 +11,16 @@ type keyMap struct {
 	YapMode        key.Binding
 	TabMode        key.Binding
 	ToggleHelpMenu key.Binding
+	Rollup         key.Binding
+	OnThisDay      key.Binding
+	CycleTheme     key.Binding
+	Outline        key.Binding
+	NextHeading    key.Binding
+	PrevHeading    key.Binding
+	LineNumbers    key.Binding
+	ExpandTree     key.Binding
+	CollapseTree   key.Binding
+	FoldTree       key.Binding
 }
 
 func newListKeyMap() *keyMap {
@@ -23,5 +33,15 @@ func newListKeyMap() *keyMap {
 		YapMode:        key.NewBinding(key.WithKeys("0", "1", "2", "3", "4"), key.WithHelp("0-4", "yap mode")),
 		TabMode:        key.NewBinding(key.WithKeys("tab"), key.WithHelp("tab", "cycle mode (input)")),
 		ToggleHelpMenu: key.NewBinding(key.WithKeys("ctrl+h"), key.WithHelp("ctrl+h", "Toggle Help")),
+		Rollup:         key.NewBinding(key.WithKeys("ctrl+g"), key.WithHelp("ctrl+g", "rollup")),
+		OnThisDay:      key.NewBinding(key.WithKeys("ctrl+o"), key.WithHelp("ctrl+o", "on this day")),
+		CycleTheme:     key.NewBinding(key.WithKeys("ctrl+t"), key.WithHelp("ctrl+t", "theme")),
+		Outline:        key.NewBinding(key.WithKeys("ctrl+b"), key.WithHelp("ctrl+b", "outline")),
+		NextHeading:    key.NewBinding(key.WithKeys("]"), key.WithHelp("]", "next heading")),
+		PrevHeading:    key.NewBinding(key.WithKeys("["), key.WithHelp("[", "previous heading")),
+		LineNumbers:    key.NewBinding(key.WithKeys("ctrl+l"), key.WithHelp("ctrl+l", "line numbers")),
+		ExpandTree:     key.NewBinding(key.WithKeys("+", "="), key.WithHelp("+", "expand tree")),
+		CollapseTree:   key.NewBinding(key.WithKeys("-"), key.WithHelp("-", "collapse tree")),
+		FoldTree:       key.NewBinding(key.WithKeys("ctrl+e"), key.WithHelp("ctrl+e", "fold nodes")),
 	}
 }
@@ -0,0 +1,233 @@
+// NOTE: Native Kitty graphics protocol encoder. Images are decoded and scaled in Go, sent as chunked base64 PNG with an id each
+// and shown through Unicode placeholders in the preview text
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"strings"
+	"sync"
+)
+
+// kittyChunkSize is the largest base64 payload the protocol allows per escape sequence.
+const kittyChunkSize = 4096
+
+var (
+	kittyMu   sync.Mutex
+	kittyIDs         = map[string]uint32{}
+	kittyNext uint32 = 1
+	// kittyShown maps the ids the terminal holds to the key of the render sent under each
+	kittyShown = map[uint32]string{}
+)
+
+// kittyImageID returns a stable id for a path so that re-sending it replaces the old image.
+func kittyImageID(path string) uint32 {
+	kittyMu.Lock()
+	defer kittyMu.Unlock()
+	if id, ok := kittyIDs[path]; ok {
+		return id
+	}
+	id := kittyNext
+	kittyNext++
+	kittyIDs[path] = id
+	return id
+}
+
+// NOTE: sendKittyImage transmits a render under id unless the terminal already holds that render, so redrawing a
+// preview reuses the image instead of resending its payload. The image is recorded so clearGraphics can delete it.
+func sendKittyImage(id uint32, r imageRender) {
+	kittyMu.Lock()
+	sent := kittyShown[id] == r.key
+	kittyShown[id] = r.key
+	kittyMu.Unlock()
+	if !sent {
+		writeGraphics(r.data)
+	}
+}
+
+// kittyDeleteShown returns the escapes deleting every image currently on screen, and forgets them.
+func kittyDeleteShown() []byte {
+	kittyMu.Lock()
+	defer kittyMu.Unlock()
+	var buf bytes.Buffer
+	for id := range kittyShown {
+		buf.WriteString(kittyDelete(id))
+	}
+	kittyShown = map[uint32]string{}
+	return buf.Bytes()
+}
+
+// kittyDelete deletes one image and frees its data in the terminal.
+func kittyDelete(id uint32) string {
+	return fmt.Sprintf("\x1b_Ga=d,d=I,i=%d,q=2\x1b\\", id)
+}
+
+// fitCells returns the largest cols x rows box, within the given one, that keeps the image's aspect ratio
+// for cells of cellW x cellH pixels, together with the pixel size the image should be scaled to.
+func fitCells(imgW, imgH, cols, rows, cellW, cellH int) (int, int, int, int) {
+	scale := math.Min(float64(cols*cellW)/float64(imgW), float64(rows*cellH)/float64(imgH))
+	pxW := max(1, int(float64(imgW)*scale))
+	pxH := max(1, int(float64(imgH)*scale))
+	fitCols := max(1, min(cols, int(math.Ceil(float64(pxW)/float64(cellW)))))
+	fitRows := max(1, min(rows, int(math.Ceil(float64(pxH)/float64(cellH)))))
+	return fitCols, fitRows, pxW, pxH
+}
+
+// scaleDown shrinks an image to w x h by averaging the source pixels under each target pixel.
+func scaleDown(src image.Image, w, h int) image.Image {
+	b := src.Bounds()
+	if w >= b.Dx() || h >= b.Dy() {
+		return src
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/w)
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n >> 8), uint8(g / n >> 8), uint8(bl / n >> 8), uint8(a / n >> 8)})
+		}
+	}
+	return dst
+}
+
+// decodeImage opens and decodes an image with the registered image/* decoders.
+func decodeImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// pngBase64 returns img as base64 PNG, the payload both the Kitty and iTerm2 protocols expect.
+func pngBase64(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// kittyTransmit builds the escapes that send a base64 PNG payload in chunks, followed by the given keys on the first chunk
+// (e.g. "a=T,c=20,r=10"). The cursor is not moved (C=1) and the terminal's replies are suppressed (q=2).
+func kittyTransmit(id uint32, payload, keys string) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(payload) || i == 0; i += kittyChunkSize {
+		end := min(len(payload), i+kittyChunkSize)
+		more := 0
+		if end < len(payload) {
+			more = 1
+		}
+		if i == 0 {
+			fmt.Fprintf(&buf, "\x1b_Gf=100,i=%d,%s,C=1,q=2,m=%d;%s\x1b\\", id, keys, more, payload[i:end])
+		} else {
+			fmt.Fprintf(&buf, "\x1b_Gm=%d;%s\x1b\\", more, payload[i:end])
+		}
+	}
+	return buf.Bytes()
+}
+
+// NOTE: encodeKitty decodes an image, scales it to fit cols x rows cells and returns the escapes that transmit it with a
+// virtual placement (U=1): nothing is drawn until placeholder cells for its id are printed, see kittyPlaceholders.
+func encodeKitty(path string, id uint32, cols, rows int) ([]byte, error) {
+	img, err := decodeImage(path)
+	if err != nil {
+		return nil, err
+	}
+	cellW, cellH := cellSize()
+	b := img.Bounds()
+	fitCols, fitRows, pxW, pxH := fitCells(b.Dx(), b.Dy(), cols, rows, cellW, cellH)
+
+	payload, err := pngBase64(scaleDown(img, pxW, pxH))
+	if err != nil {
+		return nil, err
+	}
+	return kittyTransmit(id, payload, fmt.Sprintf("a=T,U=1,c=%d,r=%d", fitCols, fitRows)), nil
+}
+
+// kittyFit returns the cells an image takes up within cols x rows, reading only the image header.
+func kittyFit(path string, cols, rows int) (int, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	conf, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, err
+	}
+	cellW, cellH := cellSize()
+	fitCols, fitRows, _, _ := fitCells(conf.Width, conf.Height, cols, rows, cellW, cellH)
+	return fitCols, fitRows, nil
+}
+
+// kittyPlaceholder is the character Kitty replaces with one cell of a virtually placed image.
+const kittyPlaceholder = '\U0010EEEE'
+
+// kittyDiacritics encode a placeholder's row and column: the nth diacritic stands for n. This is the start of the
+// table in the Kitty graphics protocol, which is all the rows and columns a preview pane needs.
+var kittyDiacritics = []rune{
+	0x0305, 0x030D, 0x030E, 0x0310, 0x0312, 0x033D, 0x033E, 0x033F, 0x0346, 0x034A,
+	0x034B, 0x034C, 0x0350, 0x0351, 0x0352, 0x0357, 0x035B, 0x0363, 0x0364, 0x0365,
+	0x0366, 0x0367, 0x0368, 0x0369, 0x036A, 0x036B, 0x036C, 0x036D, 0x036E, 0x036F,
+	0x0483, 0x0484, 0x0485, 0x0486, 0x0487, 0x0592, 0x0593, 0x0594, 0x0595, 0x0597,
+	0x0598, 0x0599, 0x059C, 0x059D, 0x059E, 0x059F, 0x05A0, 0x05A1, 0x05A8, 0x05A9,
+	0x05AB, 0x05AC, 0x05AF, 0x05C4, 0x0610, 0x0611, 0x0612, 0x0613, 0x0614, 0x0615,
+	0x0616, 0x0617, 0x0657, 0x0658, 0x0659, 0x065A, 0x065B, 0x065D, 0x065E, 0x06D6,
+	0x06D7, 0x06D8, 0x06D9, 0x06DA, 0x06DB, 0x06DC, 0x06DF, 0x06E0, 0x06E1, 0x06E2,
+	0x06E4, 0x06E7, 0x06E8, 0x06EB, 0x06EC, 0x0730, 0x0732, 0x0733, 0x0735, 0x0736,
+	0x073A, 0x073D, 0x073F, 0x0740, 0x0741, 0x0743, 0x0745, 0x0747, 0x0749, 0x074A,
+	0x07EB, 0x07EC, 0x07ED, 0x07EE, 0x07EF, 0x07F0, 0x07F1, 0x07F3, 0x0816, 0x0817,
+	0x0818, 0x0819, 0x081B, 0x081C, 0x081D, 0x081E, 0x081F, 0x0820, 0x0821, 0x0822,
+	0x0823, 0x0825, 0x0826, 0x0827, 0x0829, 0x082A, 0x082B, 0x082C, 0x082D, 0x0951,
+	0x0953, 0x0954, 0x0F82, 0x0F83, 0x0F86, 0x0F87, 0x135D, 0x135E, 0x135F, 0x17DD,
+	0x193A, 0x1A17, 0x1A75, 0x1A76, 0x1A77, 0x1A78, 0x1A79, 0x1A7A, 0x1A7B, 0x1A7C,
+	0x1B6B, 0x1B6D, 0x1B6E, 0x1B6F, 0x1B70, 0x1B71, 0x1B72, 0x1B73, 0x1CD0, 0x1CD1,
+	0x1CD2, 0x1CDA, 0x1CDB, 0x1CE0, 0x1DC0, 0x1DC1, 0x1DC3, 0x1DC4, 0x1DC5, 0x1DC6,
+	0x1DC7, 0x1DC8, 0x1DC9, 0x1DCB, 0x1DCC, 0x1DD1, 0x1DD2, 0x1DD3, 0x1DD4, 0x1DD5,
+	0x1DD6, 0x1DD7, 0x1DD8, 0x1DD9, 0x1DDA, 0x1DDB, 0x1DDC, 0x1DDD, 0x1DDE, 0x1DDF,
+	0x1DE0, 0x1DE1, 0x1DE2, 0x1DE3, 0x1DE4, 0x1DE5, 0x1DE6, 0x1DFE, 0x20D0, 0x20D1,
+	0x20D4, 0x20D5, 0x20D6, 0x20D7, 0x20DB, 0x20DC, 0x20E1, 0x20E7, 0x20E9, 0x20F0,
+	0x2CEF, 0x2CF0, 0x2CF1, 0x2DE0, 0x2DE1, 0x2DE2, 0x2DE3, 0x2DE4, 0x2DE5, 0x2DE6,
+	0x2DE7, 0x2DE8, 0x2DE9, 0x2DEA, 0x2DEB, 0x2DEC, 0x2DED, 0x2DEE, 0x2DEF, 0x2DF0,
+	0x2DF1, 0x2DF2, 0x2DF3, 0x2DF4, 0x2DF5, 0x2DF6, 0x2DF7, 0x2DF8, 0x2DF9, 0x2DFA,
+	0x2DFB, 0x2DFC, 0x2DFD, 0x2DFE, 0x2DFF,
+}
+
+// NOTE: kittyPlaceholders returns the text that shows image id as cols x rows cells. The id is carried in the 24-bit
+// foreground color and each cell names its row and column with diacritics, so the image is laid out, scrolled and
+// clipped like any other text.
+func kittyPlaceholders(id uint32, cols, rows int) string {
+	cols = min(cols, len(kittyDiacritics))
+	rows = min(rows, len(kittyDiacritics))
+	var out strings.Builder
+	for y := 0; y < rows; y++ {
+		fmt.Fprintf(&out, "\x1b[38;2;%d;%d;%dm", id>>16&0xff, id>>8&0xff, id&0xff)
+		for x := 0; x < cols; x++ {
+			out.WriteRune(kittyPlaceholder)
+			out.WriteRune(kittyDiacritics[y])
+			out.WriteRune(kittyDiacritics[x])
+		}
+		out.WriteString("\x1b[39m\n")
+	}
+	return out.String()
+}
@@ -21,14 +21,18 @@ import (
 var (
 	vaultDir       string
 	defaultYapMode yapMode = yapAll
-	Version                = "v1.0.0-dev"
+	showOnThisDay  bool
+	vimEnabled     bool
+	Version        = "v1.0.0-dev"
 )
 
 func main() {
 	modeFlag := flag.String("mode", "all", "")
 	editorFlag := flag.String("editor", "", "editor to use: nano, nvim, or inbuilt")
 	versionFlag := flag.Bool("version", false, "Print version")
-	themeFlag := flag.String("theme", "default", "theme: default, algae, gruvbox, nord, tokyonight")
+	themeFlag := flag.String("theme", "default", "theme: default, light, algae, gruvbox, nord, tokyonight, ...")
+	vimFlag := flag.Bool("vim", false, "Use vim-style modal editing in the inbuilt editor")
+	onThisDayFlag := flag.Bool("on-this-day", false, "Show notes from this day in previous years on startup")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, `YapPad — a terminal journal & note-taking app
 
@@ -40,6 +44,9 @@ Options:
                  Modes: all, daily, weekly, monthly, yearly
 
   --editor <editor name> Run with nvim or nano
+  --theme <theme> Set the color theme (default: default)
+  --vim          Vim-style modal editing in the inbuilt editor
+  --on-this-day  Show notes from this day in previous years on startup
   --version      Print version information
 
 Vault Directory:
@@ -52,6 +59,14 @@ Keybindings:
   ctrl+d       Delete selected note
   ctrl+p       Toggle preview pane
   ctrl+s       Cycle sort mode
+  ctrl+g       Generate weekly/monthly/yearly rollup
+  ctrl+o       On this day (daily mode)
+  ctrl+t       Cycle theme
+  ctrl+b       Outline of the previewed note
+  ] / [        Jump to next / previous heading in the preview
+  ctrl+l       Toggle line numbers in code previews
+  + / -        Expand / fold a level of a JSON or YAML preview
+  ctrl+e       Fold single nodes of a JSON or YAML preview
 
   0-4          Switch yap mode (0=all, 1=daily, 2=weekly, 3=monthly, 4=yearly)
   tab          Cycle yap mode while creating a note
@@ -93,6 +108,9 @@ Examples:
 		log.Fatalf("unknown mode: %s (use all, daily, weekly, monthly, yearly)", *modeFlag)
 	}
 
+	showOnThisDay = *onThisDayFlag
+	vimEnabled = *vimFlag
+
 	if flag.NArg() > 0 {
 		vaultDir = flag.Arg(0)
 	} else {
@@ -103,7 +121,24 @@ Examples:
 		vaultDir = filepath.Join(home, ".YapPad")
 	}
 
-	p := tea.NewProgram(initialModel(*editorFlag, *themeFlag), tea.WithAltScreen(), tea.WithMouseAllMotion())
+	if err := loadConfig(); err != nil {
+		log.Fatalf("invalid config %s: %v", filepath.Join(vaultDir, ".config.json"), err)
+	}
+	mode, err := detectGraphics()
+	if err != nil {
+		log.Fatalf("invalid config %s: %v", filepath.Join(vaultDir, ".config.json"), err)
+	}
+	graphics = mode
+	if err := loadSpellChecker(); err != nil {
+		log.Fatalf("could not load dictionary: %v", err)
+	}
+	styleErr := setGlamourTheme(knownTheme(*themeFlag), getTheme(*themeFlag))
+	m := initialModel(*editorFlag, *themeFlag)
+	if styleErr != nil {
+		m.startupStatus = "Style error: " + styleErr.Error()
+	}
+
+	p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseAllMotion())
 	if _, err := p.Run(); err != nil {
 		fmt.Println("error:", err)
 		os.Exit(1)
@@ -0,0 +1,227 @@
+// NOTE: Markdown-aware editing helpers for the inbuilt editor: list continuation, checkboxes, indenting, headings and inline wrapping
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// listItemRe matches a list marker with its indent and an optional checkbox, e.g. "  - [ ] " or "3. ".
+var listItemRe = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])(\s+)(\[[ xX]\]\s+)?`)
+
+// headingRe matches an ATX heading marker, e.g. "## ".
+var headingRe = regexp.MustCompile(`^(#{1,6})(\s+|$)`)
+
+// listIndent is what tab adds in front of a list item.
+const listIndent = "  "
+
+// listItem is the parsed prefix of a list line. All lengths are in runes.
+type listItem struct {
+	indent   string
+	marker   string
+	gap      string
+	checkbox string
+	prefix   int
+}
+
+func parseListItem(line string) (listItem, bool) {
+	g := listItemRe.FindStringSubmatch(line)
+	if g == nil {
+		return listItem{}, false
+	}
+	return listItem{indent: g[1], marker: g[2], gap: g[3], checkbox: g[4], prefix: len([]rune(g[0]))}, true
+}
+
+// next returns the prefix for the item that follows, counting numbered lists up and clearing checkboxes.
+func (li listItem) next() string {
+	marker := li.marker
+	if n, err := strconv.Atoi(strings.TrimRight(marker, ".)")); err == nil {
+		marker = strconv.Itoa(n+1) + marker[len(marker)-1:]
+	}
+	checkbox := ""
+	if li.checkbox != "" {
+		checkbox = "[ ] "
+	}
+	return li.indent + marker + li.gap + checkbox
+}
+
+// NOTE: editBuffer replaces the whole buffer as one undo step and moves the cursor to row, col.
+func (m model) editBuffer(value string, row, col int) (model, tea.Cmd) {
+	m.editorHistory.record(snapshotEditor(m.editorContent), editOther, true, time.Now())
+	return m.restoreSnapshot(editorSnapshot{value: value, row: row, col: col})
+}
+
+// editLine replaces the cursor's line, moving the cursor by shift columns when it sits at or after from.
+func (m model) editLine(line string, from, shift int) (model, tea.Cmd) {
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	row, col := m.editorCursor()
+	lines[row] = line
+	if col >= from {
+		col = max(from, col+shift)
+	}
+	return m.editBuffer(strings.Join(lines, "\n"), row, min(col, len([]rune(line))))
+}
+
+// NOTE: continueList handles enter on a list item: it starts the next item, or ends the list when the current item is empty.
+func (m model) continueList() (model, tea.Cmd, bool) {
+	row, col := m.editorCursor()
+	line := lineAt(m.editorContent.Value(), row)
+	li, ok := parseListItem(line)
+	if !ok || col < li.prefix {
+		return m, nil, false
+	}
+
+	runes := []rune(line)
+	if strings.TrimSpace(string(runes[li.prefix:])) == "" {
+		m, cmd := m.editLine("", 0, -col)
+		return m, cmd, true
+	}
+
+	next := li.next()
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	rest := append([]string{string(runes[:col]), next + string(runes[col:])}, lines[row+1:]...)
+	lines = append(lines[:row], rest...)
+	m, cmd := m.editBuffer(strings.Join(lines, "\n"), row+1, len([]rune(next)))
+	return m, cmd, true
+}
+
+// NOTE: indentListItem indents or outdents the list item under the cursor. Other lines are left to the textarea.
+func (m model) indentListItem(outdent bool) (model, tea.Cmd, bool) {
+	row, _ := m.editorCursor()
+	line := lineAt(m.editorContent.Value(), row)
+	if _, ok := parseListItem(line); !ok {
+		return m, nil, false
+	}
+	if !outdent {
+		m, cmd := m.editLine(listIndent+line, 0, len(listIndent))
+		return m, cmd, true
+	}
+
+	trimmed := strings.TrimPrefix(line, listIndent)
+	if trimmed == line {
+		trimmed = strings.TrimPrefix(line, "\t")
+	}
+	if trimmed == line {
+		trimmed = strings.TrimLeft(line, " ")
+	}
+	m, cmd := m.editLine(trimmed, 0, len(trimmed)-len(line))
+	return m, cmd, true
+}
+
+// NOTE: toggleCheckbox flips "[ ]" and "[x]" on the current line, adding a checkbox to list items and plain lines that lack one.
+func (m model) toggleCheckbox() (model, tea.Cmd) {
+	row, _ := m.editorCursor()
+	line := lineAt(m.editorContent.Value(), row)
+	li, ok := parseListItem(line)
+
+	switch {
+	case ok && li.checkbox != "":
+		at := len([]rune(li.indent + li.marker + li.gap))
+		runes := []rune(line)
+		mark := 'x'
+		if runes[at+1] != ' ' {
+			mark = ' '
+		}
+		runes[at+1] = mark
+		return m.editLine(string(runes), len(runes), 0)
+	case ok:
+		at := len([]rune(li.indent + li.marker + li.gap))
+		runes := []rune(line)
+		return m.editLine(string(runes[:at])+"[ ] "+string(runes[at:]), at, 4)
+	default:
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		return m.editLine(line[:indent]+"- [ ] "+line[indent:], indent, 6)
+	}
+}
+
+// NOTE: shiftHeading promotes (fewer #) or demotes (more #) the heading on the current line. Demoting a plain line makes it a level 1 heading.
+func (m model) shiftHeading(promote bool) (model, tea.Cmd) {
+	row, _ := m.editorCursor()
+	line := lineAt(m.editorContent.Value(), row)
+	g := headingRe.FindStringSubmatch(line)
+
+	switch {
+	case g == nil && promote:
+		return m, nil
+	case g == nil:
+		return m.editLine("# "+line, 0, 2)
+	case promote && len(g[1]) == 1:
+		return m.editLine(line[len(g[0]):], 0, -len(g[0]))
+	case promote:
+		return m.editLine(line[1:], 0, -1)
+	case len(g[1]) == 6:
+		return m, nil
+	default:
+		return m.editLine("#"+line, 0, 1)
+	}
+}
+
+// NOTE: wrapInline wraps the visual selection, or the word under the cursor, in marker (e.g. "**"). Text already wrapped in marker is unwrapped.
+func (m model) wrapInline(marker string) (model, tea.Cmd) {
+	b, off := m.vimBufferState()
+	start, end, ok := m.vimSelection()
+	if ok {
+		m.vim.mode = vimNormal
+	} else {
+		start, end = off, off
+		for start > 0 && charClass(b.runes[start-1]) == 1 {
+			start--
+		}
+		for end < len(b.runes) && charClass(b.runes[end]) == 1 {
+			end++
+		}
+	}
+
+	mk := []rune(marker)
+	n := len(mk)
+	if start >= n && end+n <= len(b.runes) &&
+		string(b.runes[start-n:start]) == marker && string(b.runes[end:end+n]) == marker {
+		value := string(b.runes[:start-n]) + string(b.runes[start:end]) + string(b.runes[end+n:])
+		row, col := b.position(max(start-n, off-n))
+		return m.editBuffer(value, row, col)
+	}
+
+	value := string(b.runes[:start]) + marker + string(b.runes[start:end]) + marker + string(b.runes[end:])
+	row, col := b.position(off + n)
+	return m.editBuffer(value, row, col)
+}
+
+// NOTE: updateMarkdown runs the formatting keys that work in every mode. ok is false for other keys.
+func (m model) updateMarkdown(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	var cmd tea.Cmd
+	switch msg.String() {
+	case "ctrl+t":
+		m, cmd = m.toggleCheckbox()
+	case "alt+,":
+		m, cmd = m.shiftHeading(true)
+	case "alt+.":
+		m, cmd = m.shiftHeading(false)
+	case "alt+b":
+		m, cmd = m.wrapInline("**")
+	case "alt+i":
+		m, cmd = m.wrapInline("*")
+	case "alt+`":
+		m, cmd = m.wrapInline("`")
+	default:
+		return m, nil, false
+	}
+	return m, cmd, true
+}
+
+// NOTE: updateListKeys runs the list keys that only apply while typing (insert mode under --vim).
+func (m model) updateListKeys(msg tea.KeyMsg) (model, tea.Cmd, bool) {
+	switch msg.String() {
+	case "enter":
+		return m.continueList()
+	case "tab":
+		return m.indentListItem(false)
+	case "shift+tab":
+		return m.indentListItem(true)
+	}
+	return m, nil, false
+}
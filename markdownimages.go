@@ -0,0 +1,137 @@
+// NOTE: Images embedded in markdown notes (![alt](attachments/arch.png)) are drawn inline in the rendered preview
+
+package main
+
+import (
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var imageRefRe = regexp.MustCompile(`!\[([^\]]*)\]\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)`)
+
+// imageMarker stands in for an image while the note goes through glamour, and is replaced by the image afterwards.
+const imageMarker = "YAPPADIMAGE%dEND"
+
+// resolveImageRef finds the file an image reference points to: relative to the note first, then to the vault root.
+// Remote images are not fetched.
+func resolveImageRef(note, ref string) (string, bool) {
+	if strings.Contains(ref, "://") || strings.HasPrefix(ref, "data:") {
+		return "", false
+	}
+	if unescaped, err := url.PathUnescape(ref); err == nil {
+		ref = unescaped
+	}
+	candidates := []string{filepath.Join(vaultDir, ref)}
+	if !strings.HasPrefix(ref, "/") {
+		candidates = append([]string{filepath.Join(filepath.Dir(note), ref)}, candidates...)
+	}
+	for _, path := range candidates {
+		if info, err := os.Stat(path); err == nil && !info.IsDir() && isImageFile(path) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+// NOTE: embedImages swaps every local image reference outside code for a marker paragraph, returning the new source and
+// the image paths in marker order.
+func embedImages(note, content string) (string, []string) {
+	var images []string
+	lines := strings.Split(content, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") || strings.HasPrefix(strings.TrimSpace(line), "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || strings.HasPrefix(line, "    ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		lines[i] = imageRefRe.ReplaceAllStringFunc(line, func(ref string) string {
+			// Leave references inside inline code alone
+			if at := strings.Index(line, ref); strings.Count(line[:at], "`")%2 == 1 {
+				return ref
+			}
+			path, ok := resolveImageRef(note, imageRefRe.FindStringSubmatch(ref)[2])
+			if !ok {
+				return ref
+			}
+			images = append(images, path)
+			return "\n\n" + fmt.Sprintf(imageMarker, len(images)-1) + "\n\n"
+		})
+	}
+	return strings.Join(lines, "\n"), images
+}
+
+// inlineImage renders one embedded image as preview text. Kitty images are transmitted and shown through placeholders;
+// Sixel and iTerm2 can't draw inside scrolling text so they use half-blocks, and without graphics a box names the image.
+func inlineImage(path string, cols, rows int) string {
+	mode := graphics
+	if mode.overlay() {
+		mode = graphicsHalfBlock
+	}
+	if mode == graphicsNone {
+		return imageBox(path)
+	}
+
+	r, err := cachedImage(mode, path, cols, rows)
+	if err != nil {
+		return imageBox(path)
+	}
+	if mode != graphicsKitty {
+		return strings.TrimSuffix(string(r.data), "\n")
+	}
+	id := kittyImageID(path)
+	sendKittyImage(id, r)
+	return strings.TrimSuffix(kittyPlaceholders(id, r.cols, r.rows), "\n")
+}
+
+// imageBox is the stand-in for an image that can't be drawn: its name and dimensions in a box.
+func imageBox(path string) string {
+	label := filepath.Base(path)
+	if f, err := os.Open(path); err == nil {
+		if conf, _, err := image.DecodeConfig(f); err == nil {
+			label += fmt.Sprintf("\n%d×%d", conf.Width, conf.Height)
+		}
+		f.Close()
+	}
+	return lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1).Faint(true).Render("🖼  " + label)
+}
+
+// NOTE: expandImages replaces each marker line in rendered markdown with its image, keeping glamour's left margin.
+// Images are sized to the pane width and half its height, so the text around them stays in view.
+func expandImages(rendered string, images []string, width, height int) string {
+	if len(images) == 0 {
+		return rendered
+	}
+	rows := max(4, height/2)
+
+	lines := strings.Split(rendered, "\n")
+	for i, line := range lines {
+		for n, path := range images {
+			at := strings.Index(line, fmt.Sprintf(imageMarker, n))
+			if at < 0 {
+				continue
+			}
+			margin := strings.Repeat(" ", lipgloss.Width(line[:at]))
+			img := inlineImage(path, max(1, width-len(margin)*2), rows)
+			lines[i] = margin + strings.ReplaceAll(img, "\n", "\n"+margin)
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderNote renders a markdown note for the preview, with spelling marked and embedded images drawn inline.
+func renderNote(path, content string, width, height int) string {
+	source, images := embedImages(path, content)
+	rendered := underlineMarked(renderMarkdown(markMisspelled(source)))
+	return expandImages(rendered, images, width, height)
+}
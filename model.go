@@ -1,6 +1,6 @@
 /*
 NOTE:
-Defines the model struct with all state, initialModel constructor, Init, loadFileOrImage, switchYapMode, and resolveFilePath
+Defines the model struct with all state, initialModel constructor, Init, loadFileOrImage, switchYapMode, resolveFilePath and note creation
 */
 package main
 
@@ -9,6 +9,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -44,12 +46,57 @@ type model struct {
 	editorMode        bool
 	editorFile        string
 	editorContent     textarea.Model
+	editorSaved       string
+	editorStatus      string
+	editorConfirm     bool
+	editorRev         int
+	spanCache         *spanCache
+	editorHistory     editHistory
+	editorTop         int
+	editorPreview     bool
+	editorPreviewPane viewport.Model
+	wordStats         wordStats
+	spellMenu         spellMenu
+	search            editorSearch
+	vim               vimState
+	recoveries        []string
+	recoveryDiff      []diffLine
+	recoveryScroll    int
 	spinner           spinner.Model
 	loadingFile       bool
 	theme             Theme
+	themeName         string
+	pendingNote       string
+	noteDate          time.Time
+	templateSrc       string
+	templates         []string
+	templateIndex     int
+	promptFields      []string
+	promptIndex       int
+	promptValues      map[string]string
+	promptInput       textinput.Model
+	onThisDay         bool
+	memories          []memory
+	memoryIndex       int
+	outline           []heading
+	outlineOpen       bool
+	outlineIndex      int
+	previewLanguage   string
+	lineNumbers       bool
+	previewTree       []*treeNode
+	treeRows          []*treeNode
+	treeFocus         bool
+	treeCursor        int
+	treeDepth         int
+	startupStatus     string
 }
 
-func (m model) Init() tea.Cmd { return nil }
+func (m model) Init() tea.Cmd {
+	if m.startupStatus == "" {
+		return nil
+	}
+	return func() tea.Msg { return statusMsg(m.startupStatus) }
+}
 
 func initialModel(editor string, themeName string) model {
 	listKeys := newListKeyMap()
@@ -76,12 +123,22 @@ func initialModel(editor string, themeName string) model {
 			listKeys.ToggleHelpMenu,
 			listKeys.CycleSort,
 			listKeys.YapMode,
+			listKeys.Rollup,
+			listKeys.OnThisDay,
+			listKeys.CycleTheme,
+			listKeys.Outline,
+			listKeys.NextHeading,
+			listKeys.PrevHeading,
+			listKeys.LineNumbers,
+			listKeys.ExpandTree,
+			listKeys.CollapseTree,
+			listKeys.FoldTree,
 		}
 	}
 
 	t := getTheme(themeName)
 	ti := textinput.New()
-	ti.Placeholder = fmt.Sprintf("%s/%s (default)", defaultMode.defaultNoteDir(), defaultMode.defaultNoteName())
+	ti.Placeholder = fmt.Sprintf("%s/%s (default, or @date)", defaultMode.defaultNoteDir(), defaultMode.defaultNoteName())
 	ti.CharLimit = 128
 	ti.Width = 40
 
@@ -90,14 +147,28 @@ func initialModel(editor string, themeName string) model {
 	di.CharLimit = 128
 	di.Width = 40
 
+	pi := textinput.New()
+	pi.CharLimit = 256
+	pi.Width = 40
+
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("62"))
 
+	var memories []memory
+	if showOnThisDay {
+		memories = collectMemories(time.Now())
+	}
+
 	return model{
+		recoveries:  findRecoverableSwaps(),
+		onThisDay:   len(memories) > 0,
+		memories:    memories,
 		list:        l,
 		input:       ti,
 		descInput:   di,
+		promptInput: pi,
+		search:      newEditorSearch(),
 		spinner:     s,
 		keys:        listKeys,
 		viewport:    viewport.New(0, 0),
@@ -106,18 +177,38 @@ func initialModel(editor string, themeName string) model {
 		yapMode:     defaultMode,
 		editor:      editor,
 		theme:       t,
+		themeName:   knownTheme(themeName),
+		treeDepth:   defaultTreeDepth,
+		spanCache:   &spanCache{rev: -1},
 	}
 }
 
+// previewImageSize is the cell area an image is rendered into in the preview pane, by every backend. Prerendering
+// uses the same size so its cache entries are the ones the real render looks up.
+func (m model) previewImageSize() (cols, rows int) {
+	return m.viewport.Width - 1, m.viewport.Height
+}
+
 // NOTE: loadFileOrImage determines if a file is an image or text and dispatches to the appropriate handler.
 func (m model) loadFileOrImage(path string) tea.Cmd {
+	cols, rows := m.previewImageSize()
+	prerender := m.prerenderNeighbours(cols, rows)
+	if isImageFile(path) && graphics == graphicsKitty {
+		return tea.Batch(prerender, tea.Sequence(
+			clearGraphics(),
+			renderKittyImage(path, cols, rows),
+		))
+	}
+	if isImageFile(path) && !graphics.overlay() {
+		return tea.Batch(prerender, tea.Sequence(
+			clearGraphics(),
+			renderImageText(path, cols, rows),
+		))
+	}
 	if isImageFile(path) {
 		listWidth := m.width / 2
 		xOffset := listWidth + 6
 
-		cols := m.viewport.Width - 1
-		rows := m.viewport.Height
-
 		// Adjust yOffset based on aspect ratio
 		yOffset := 4 + 4
 		ratio, err := getImageAspectRatio(path)
@@ -126,17 +217,23 @@ func (m model) loadFileOrImage(path string) tea.Cmd {
 			yOffset = 4 + 3
 		}
 
-		return tea.Sequence(
-			clearKittyGraphics(),
+		return tea.Batch(prerender, tea.Sequence(
+			clearGraphics(),
 			func() tea.Msg { return clearViewportMsg{} },
 			renderImage(path, cols, rows, xOffset, yOffset),
-		)
+		))
 	}
 	m.showingImage = false
-	return tea.Sequence(
-		clearKittyGraphics(),
-		readFile(path),
-	)
+	return tea.Batch(prerender, tea.Sequence(
+		clearGraphics(),
+		readFile(path, previewOptions{
+			width:       m.viewport.Width - 1,
+			height:      m.viewport.Height,
+			theme:       m.theme,
+			lineNumbers: m.lineNumbers,
+			treeDepth:   m.treeDepth,
+		}),
+	))
 }
 
 // NOTE: switchYapMode changes the yap mode, refreshes the list, and loads the first item's preview (or clears the viewport if the list is empty).
@@ -152,7 +249,7 @@ func (m model) switchYapMode(mode yapMode) (tea.Model, tea.Cmd) {
 		return m, m.loadFileOrImage(m.resolveFilePath(i.title))
 	}
 	m.viewport.SetContent("")
-	return m, clearKittyGraphics()
+	return m, clearGraphics()
 }
 
 // NOTE: resolveFilePath resolves the full path for a file given its display title.
@@ -162,3 +259,114 @@ func (m model) resolveFilePath(title string) string {
 	}
 	return filepath.Join(vaultDir, m.yapMode.subdir(), title)
 }
+
+// NOTE: templateData builds the template context for the note currently being created.
+func (m model) templateData() templateData {
+	title := strings.TrimSuffix(filepath.Base(m.pendingNote), filepath.Ext(m.pendingNote))
+	date := m.noteDate
+	if date.IsZero() {
+		date = time.Now()
+	}
+	dir, err := filepath.Rel(vaultDir, filepath.Dir(m.pendingNote))
+	if err != nil {
+		dir = ""
+	}
+	return newTemplateData(m.yapMode, date, title, dir)
+}
+
+// NOTE: applyTemplate loads the chosen template and asks for its prompt fields before creating the note.
+// An empty name creates the note without a template.
+func (m model) applyTemplate(name string) (tea.Model, tea.Cmd) {
+	m.templateSrc = ""
+	if name != "" {
+		src, ok := readTemplate(name)
+		if !ok {
+			return m.cancelCreate("Template not found: " + name)
+		}
+		m.templateSrc = src
+	}
+	if m.templateSrc == "" {
+		return m.createNote()
+	}
+
+	fields, err := templateFields(m.templateSrc, m.templateData())
+	if err != nil {
+		return m.cancelCreate("Template error: " + err.Error())
+	}
+	if len(fields) == 0 {
+		return m.createNote()
+	}
+	m.inputStep = 3
+	m.promptFields = fields
+	m.promptIndex = 0
+	m.promptValues = map[string]string{}
+	m.descInput.Blur()
+	m.promptInput.SetValue("")
+	m.promptInput.Placeholder = fields[0]
+	m.promptInput.Focus()
+	return m, nil
+}
+
+// NOTE: createNote writes the pending note (rendering its template, if any) and opens it in the editor.
+func (m model) createNote() (tea.Model, tea.Cmd) {
+	path := m.pendingNote
+	desc := m.descInput.Value()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return m.cancelCreate("Error: " + err.Error())
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		var content []byte
+		if m.templateSrc != "" {
+			content, err = renderTemplate(m.templateSrc, m.templateData(), m.promptValues)
+			if err != nil {
+				return m.cancelCreate("Template error: " + err.Error())
+			}
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return m.cancelCreate("Error: " + err.Error())
+		}
+	}
+
+	writeMetaDesc(path, desc)
+
+	newM, _ := m.cancelCreate("")
+	m = newM.(model)
+
+	rel, _ := filepath.Rel(vaultDir, path)
+	if m.yapMode != yapAll {
+		m.selectedFile = filepath.Base(path)
+	} else {
+		m.selectedFile = rel
+	}
+	if m.editor == "inbuilt" {
+		var editorCmd tea.Cmd
+		m, editorCmd = openInbuiltEditor(path, m)
+		return m, editorCmd
+	}
+	return m, openInEditor(path, m.editor)
+}
+
+// NOTE: cancelCreate leaves input mode and resets all creation state, optionally showing a status message.
+func (m model) cancelCreate(status string) (tea.Model, tea.Cmd) {
+	m.inputMode = false
+	m.inputStep = 0
+	m.input.SetValue("")
+	m.descInput.SetValue("")
+	m.promptInput.SetValue("")
+	m.promptInput.Blur()
+	m.input.Focus()
+	m.pendingNote = ""
+	m.noteDate = time.Time{}
+	m.templateSrc = ""
+	m.templates = nil
+	m.templateIndex = 0
+	m.promptFields = nil
+	m.promptValues = nil
+	m.list.SetItems(listFiles(m.sortMode, m.yapMode))
+	if status != "" {
+		return m, m.list.NewStatusMessage(status)
+	}
+	return m, nil
+}
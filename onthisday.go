@@ -0,0 +1,143 @@
+// NOTE: "On this day" retrospective. Collects notes from the same day, ISO week and month in previous years
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// memory is a note from a past year shown in the retrospective view.
+type memory struct {
+	section string
+	title   string
+	path    string
+	date    time.Time
+}
+
+const (
+	sectionDay   = "On this day"
+	sectionWeek  = "This week in past years"
+	sectionMonth = "This month in past years"
+)
+
+// datedNote is a note together with the date it belongs to.
+type datedNote struct {
+	path string
+	date time.Time
+}
+
+// datedNotes lists the notes of a mode with the date encoded in their filename,
+// falling back to the file's creation time.
+func datedNotes(mode yapMode) []datedNote {
+	dir := filepath.Join(vaultDir, mode.subdir())
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var notes []datedNote
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		d, ok := mode.noteDate(f.Name())
+		if !ok {
+			info, err := f.Info()
+			if err != nil {
+				continue
+			}
+			d = info.ModTime()
+			if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+				d = getCreationTime(stat)
+			}
+		}
+		notes = append(notes, datedNote{path: path, date: d})
+	}
+	return notes
+}
+
+// collectMemories returns notes from previous years matching today's day, ISO week and month.
+func collectMemories(now time.Time) []memory {
+	year, week := now.ISOWeek()
+	var memories []memory
+	seen := map[string]bool{}
+
+	add := func(section string, n datedNote) {
+		if seen[n.path] {
+			return
+		}
+		seen[n.path] = true
+		rel, _ := filepath.Rel(vaultDir, n.path)
+		memories = append(memories, memory{section: section, title: rel, path: n.path, date: n.date})
+	}
+
+	daily := datedNotes(yapDaily)
+	for _, n := range daily {
+		if n.date.Year() < now.Year() && n.date.Month() == now.Month() && n.date.Day() == now.Day() {
+			add(sectionDay, n)
+		}
+	}
+	for _, n := range append(datedNotes(yapWeekly), daily...) {
+		if y, w := n.date.ISOWeek(); y < year && w == week {
+			add(sectionWeek, n)
+		}
+	}
+	for _, n := range datedNotes(yapMonthly) {
+		if n.date.Year() < now.Year() && n.date.Month() == now.Month() {
+			add(sectionMonth, n)
+		}
+	}
+
+	order := map[string]int{sectionDay: 0, sectionWeek: 1, sectionMonth: 2}
+	sort.SliceStable(memories, func(i, j int) bool {
+		if memories[i].section != memories[j].section {
+			return order[memories[i].section] < order[memories[j].section]
+		}
+		return memories[i].date.After(memories[j].date)
+	})
+	return memories
+}
+
+// NOTE: onThisDayView renders the grouped memories with the cursor on the selected one.
+func (m model) onThisDayView() string {
+	var b strings.Builder
+	heading := lipgloss.NewStyle().Foreground(m.theme.Primary).Bold(true)
+	normal := lipgloss.NewStyle().Foreground(m.theme.Text)
+	selected := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+	sub := lipgloss.NewStyle().Foreground(m.theme.SubText)
+
+	b.WriteString(m.statusStyle().Render(time.Now().Format("Monday, January 2") + " · enter: open  esc: close"))
+	b.WriteString("\n")
+
+	if len(m.memories) == 0 {
+		b.WriteString("\n" + sub.Render("  Nothing from previous years yet."))
+		return b.String()
+	}
+
+	section := ""
+	for i, mem := range m.memories {
+		if mem.section != section {
+			section = mem.section
+			b.WriteString("\n  " + heading.Render(section) + "\n")
+		}
+		ago := time.Now().Year() - mem.date.Year()
+		line := fmt.Sprintf("%s  %s", mem.title, sub.Render(fmt.Sprintf("%d year(s) ago", ago)))
+		if desc := readMetaDesc(mem.path); desc != "" {
+			line += sub.Render(" · " + desc)
+		}
+		if i == m.memoryIndex {
+			b.WriteString("  " + selected.Render("> ") + selected.Render(mem.title) + strings.TrimPrefix(line, mem.title) + "\n")
+		} else {
+			b.WriteString("    " + normal.Render(mem.title) + strings.TrimPrefix(line, mem.title) + "\n")
+		}
+	}
+	return b.String()
+}
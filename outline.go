@@ -0,0 +1,207 @@
+// NOTE: Outline panel (ctrl+b) built from a note's headings, and jumping between headings in the preview with ] and [
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+var (
+	atxHeadingRe    = regexp.MustCompile(`^(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	orgHeadingRe    = regexp.MustCompile(`^(\*+)\s+(.+)$`)
+	setextUnderline = regexp.MustCompile(`^\s{0,3}(=+|-+)\s*$`)
+	inlineLinkRe    = regexp.MustCompile(`!?\[([^\]]*)\]\([^)]*\)`)
+)
+
+// headingText drops inline markup from a heading, so it reads (and can be found) as it is rendered.
+func headingText(s string) string {
+	s = inlineLinkRe.ReplaceAllString(s, "$1")
+	return strings.TrimSpace(strings.NewReplacer("**", "", "__", "", "`", "", "~~", "").Replace(s))
+}
+
+// heading is one entry of a note's outline. line is where it shows up in the preview, once located.
+type heading struct {
+	level int
+	text  string
+	line  int
+}
+
+// NOTE: parseHeadings reads the outline of a note. Org files use "*" headings; other notes use markdown "#" and
+// underlined (setext) headings, and plain text files also count short all-caps lines. Code blocks are skipped.
+func parseHeadings(path, content string) []heading {
+	ext := strings.ToLower(filepath.Ext(path))
+	lines := strings.Split(content, "\n")
+	var headings []heading
+	inFence := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence || trimmed == "" {
+			continue
+		}
+
+		if ext == ".org" {
+			if sm := orgHeadingRe.FindStringSubmatch(line); sm != nil {
+				headings = append(headings, heading{level: len(sm[1]), text: headingText(sm[2])})
+			}
+			continue
+		}
+		if sm := atxHeadingRe.FindStringSubmatch(line); sm != nil && sm[2] != "" {
+			headings = append(headings, heading{level: len(sm[1]), text: headingText(sm[2])})
+			continue
+		}
+		if i+1 < len(lines) && !setextUnderline.MatchString(line) && !listItemRe.MatchString(line) {
+			if sm := setextUnderline.FindStringSubmatch(lines[i+1]); sm != nil {
+				level := 1
+				if sm[1][0] == '-' {
+					level = 2
+				}
+				headings = append(headings, heading{level: level, text: headingText(trimmed)})
+				continue
+			}
+		}
+		if ext == ".txt" && isShoutedHeading(trimmed) {
+			headings = append(headings, heading{level: 1, text: trimmed})
+		}
+	}
+	return headings
+}
+
+// isShoutedHeading reports whether a plain text line reads like a heading: short, all caps, with at least one letter.
+func isShoutedHeading(line string) bool {
+	if len(line) > 60 {
+		return false
+	}
+	letters := false
+	for _, r := range line {
+		if unicode.IsLower(r) {
+			return false
+		}
+		letters = letters || unicode.IsLetter(r)
+	}
+	return letters
+}
+
+// NOTE: locateHeadings finds each heading's line in the wrapped preview, searching forward from the previous one since
+// glamour and word wrapping don't keep the source's line numbers. Headings that can't be found are dropped.
+func locateHeadings(headings []heading, preview string) []heading {
+	lines := strings.Split(ansi.Strip(preview), "\n")
+	var located []heading
+	from := 0
+	for _, h := range headings {
+		// A long heading may be wrapped, so match on its start
+		text := strings.TrimSpace(h.text)
+		if r := []rune(text); len(r) > 20 {
+			text = string(r[:20])
+		}
+		for i := from; i < len(lines); i++ {
+			if strings.Contains(lines[i], text) {
+				h.line = i
+				located = append(located, h)
+				from = i + 1
+				break
+			}
+		}
+	}
+	return located
+}
+
+// currentHeading is the index of the last heading at or above the top of the preview.
+func (m model) currentHeading() int {
+	current := 0
+	for i, h := range m.outline {
+		if h.line <= m.viewport.YOffset {
+			current = i
+		}
+	}
+	return current
+}
+
+// NOTE: jumpHeading scrolls the preview to the next (or previous) heading below (or above) its top line.
+func (m model) jumpHeading(forward bool) (tea.Model, tea.Cmd) {
+	if len(m.outline) == 0 {
+		return m, m.list.NewStatusMessage("No headings in this note")
+	}
+	top := m.viewport.YOffset
+	if forward {
+		for _, h := range m.outline {
+			if h.line > top {
+				m.viewport.SetYOffset(h.line)
+				return m, nil
+			}
+		}
+		return m, nil
+	}
+	for i := len(m.outline) - 1; i >= 0; i-- {
+		if m.outline[i].line < top {
+			m.viewport.SetYOffset(m.outline[i].line)
+			return m, nil
+		}
+	}
+	m.viewport.GotoTop()
+	return m, nil
+}
+
+// NOTE: openOutline shows the outline panel in place of the list, starting at the section on screen.
+func (m model) openOutline() (tea.Model, tea.Cmd) {
+	if !m.showPreview || m.showingImage || len(m.outline) == 0 {
+		return m, m.list.NewStatusMessage("No headings in this note")
+	}
+	m.outlineOpen = true
+	m.outlineIndex = m.currentHeading()
+	return m, nil
+}
+
+// NOTE: updateOutline moves through the outline, scrolling the preview along with the selection.
+func (m model) updateOutline(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "up", "k":
+		m.outlineIndex = max(0, m.outlineIndex-1)
+	case "down", "j":
+		m.outlineIndex = min(len(m.outline)-1, m.outlineIndex+1)
+	case "home", "g":
+		m.outlineIndex = 0
+	case "end", "G":
+		m.outlineIndex = len(m.outline) - 1
+	case "enter", "esc", "q", "ctrl+b":
+		m.outlineOpen = false
+		return m, nil
+	case "ctrl+c":
+		return m, tea.Quit
+	default:
+		return m, nil
+	}
+	m.viewport.SetYOffset(m.outline[m.outlineIndex].line)
+	return m, nil
+}
+
+// outlineView lists the headings indented by level, scrolled to keep the selection in view.
+func (m model) outlineView(width, height int) string {
+	normal := lipgloss.NewStyle().Foreground(m.theme.Text)
+	selected := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+
+	rows := []string{m.statusStyle().Render("Outline · enter: close"), ""}
+	visible := max(1, height-len(rows))
+	start := max(0, min(m.outlineIndex-visible/2, len(m.outline)-visible))
+	for i := start; i < min(len(m.outline), start+visible); i++ {
+		h := m.outline[i]
+		indent := strings.Repeat("  ", h.level-1)
+		text := ansi.Truncate(indent+h.text, max(1, width-4), "…")
+		if i == m.outlineIndex {
+			rows = append(rows, "  "+selected.Render("> "+text))
+		} else {
+			rows = append(rows, "    "+normal.Render(text))
+		}
+	}
+	return lipgloss.NewStyle().Width(width).Height(height).Render(strings.Join(rows, "\n"))
+}
@@ -0,0 +1,200 @@
+// NOTE: Period rollups. Builds a weekly/monthly/yearly draft from the notes of the period below it
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// defaultRollupTemplate is used when .templates/rollup.md does not exist.
+const defaultRollupTemplate = `# {{.Mode}} rollup: {{.Title}}
+
+{{date "Jan 2, 2006" .Start}} – {{date "Jan 2, 2006" .End}} · {{.Prev}} · {{.Next}}
+{{range .Entries}}
+## {{.Link}}
+{{with .Description}}
+_{{.}}_
+{{end}}{{if .Headings}}
+{{range .Headings}}- {{.}}
+{{end}}{{end}}{{if .Done}}
+{{range .Done}}- [x] {{.}}
+{{end}}{{end}}{{end}}`
+
+// rollupEntry summarises one source note of a rollup.
+type rollupEntry struct {
+	Date        time.Time
+	Link        noteLink
+	Description string
+	Headings    []string
+	Done        []string
+}
+
+// rollupData is what the rollup template is executed against. It embeds the
+// regular template fields so period dates and links work the same way.
+type rollupData struct {
+	templateData
+	Entries []rollupEntry
+}
+
+type rollupDoneMsg struct {
+	path string
+	err  error
+}
+
+var doneTaskRe = regexp.MustCompile(`^\s*[-*+]\s+\[[xX]\]\s+(.*)$`)
+
+// rollupSource returns the mode whose notes are collected into a rollup.
+func (y yapMode) rollupSource() (yapMode, bool) {
+	switch y {
+	case yapWeekly:
+		return yapDaily, true
+	case yapMonthly:
+		return yapWeekly, true
+	case yapYearly:
+		return yapMonthly, true
+	default:
+		return yapAll, false
+	}
+}
+
+// collectRollupEntries reads every source note that falls inside the period containing date.
+func collectRollupEntries(mode yapMode, date time.Time) []rollupEntry {
+	source, _ := mode.rollupSource()
+	target := mode.noteName(date)
+
+	dir := filepath.Join(vaultDir, source.subdir())
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var entries []rollupEntry
+	for _, f := range files {
+		if f.IsDir() || strings.HasPrefix(f.Name(), ".") {
+			continue
+		}
+		d, ok := source.noteDate(f.Name())
+		if !ok {
+			continue
+		}
+		// A week belongs to the month/year that contains its Thursday, as in ISO 8601
+		member := d
+		if source == yapWeekly {
+			member = d.AddDate(0, 0, 3)
+		}
+		if mode.noteName(member) != target {
+			continue
+		}
+
+		path := filepath.Join(dir, f.Name())
+		headings, done := scanNote(path)
+		entries = append(entries, rollupEntry{
+			Date:        d,
+			Link:        source.link(d, mode.subdir()),
+			Description: readMetaDesc(path),
+			Headings:    headings,
+			Done:        done,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Date.Before(entries[j].Date)
+	})
+	return entries
+}
+
+// scanNote extracts headings and completed tasks from a markdown note.
+func scanNote(path string) (headings, done []string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+
+	inFence := false
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			if h := strings.TrimSpace(strings.TrimLeft(line, "#")); h != "" {
+				headings = append(headings, h)
+			}
+			continue
+		}
+		if match := doneTaskRe.FindStringSubmatch(line); match != nil {
+			done = append(done, strings.TrimSpace(match[1]))
+		}
+	}
+	return headings, done
+}
+
+/*
+	NOTE:
+
+buildRollup renders the rollup for the period containing date. The period
+note itself is written when it doesn't exist yet; otherwise the draft goes
+next to it as <name>-rollup.md (or -rollup-2.md, ... when earlier drafts
+exist) so existing notes are never overwritten.
+*/
+func buildRollup(mode yapMode, date time.Time) tea.Cmd {
+	return func() tea.Msg {
+		src, ok := readTemplate("rollup.md")
+		if !ok {
+			src = defaultRollupTemplate
+		}
+
+		name := mode.noteName(date)
+		data := rollupData{
+			templateData: newTemplateData(mode, date, strings.TrimSuffix(name, ".md"), mode.subdir()),
+			Entries:      collectRollupEntries(mode, date),
+		}
+		content, err := renderTemplate(src, data, nil)
+		if err != nil {
+			return rollupDoneMsg{err: fmt.Errorf("template error: %w", err)}
+		}
+
+		note := filepath.Join(vaultDir, mode.subdir(), name)
+		if err := os.MkdirAll(filepath.Dir(note), 0o755); err != nil {
+			return rollupDoneMsg{err: err}
+		}
+		// O_EXCL makes taking a name and creating the file one step, so nothing is written over
+		path := note
+		for n := 1; ; n++ {
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+			if os.IsExist(err) {
+				path = strings.TrimSuffix(note, ".md") + "-rollup.md"
+				if n > 1 {
+					path = strings.TrimSuffix(note, ".md") + fmt.Sprintf("-rollup-%d.md", n)
+				}
+				continue
+			}
+			if err != nil {
+				return rollupDoneMsg{err: err}
+			}
+			_, err = f.Write(content)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				return rollupDoneMsg{err: err}
+			}
+			return rollupDoneMsg{path: path}
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// NOTE: Incremental find and replace for the inbuilt editor
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// searchMatch is a match on a single line, in rune offsets.
+type searchMatch struct {
+	row   int
+	start int
+	end   int
+}
+
+type editorSearch struct {
+	active        bool
+	query         textinput.Model
+	replace       textinput.Model
+	replacing     bool
+	caseSensitive bool
+	regex         bool
+	matches       []searchMatch
+	current       int
+	err           error
+}
+
+func newEditorSearch() editorSearch {
+	q := textinput.New()
+	q.Prompt = "Find: "
+	q.Placeholder = "search"
+	q.Width = 30
+	// ctrl+f refocuses the query and ctrl+a replaces all, so they don't move the cursor
+	q.KeyMap.CharacterForward.SetKeys("right")
+	q.KeyMap.LineStart.SetKeys("home")
+
+	r := textinput.New()
+	r.Prompt = "Replace: "
+	r.Placeholder = "replacement"
+	r.Width = 30
+	r.KeyMap = q.KeyMap
+
+	return editorSearch{query: q, replace: r}
+}
+
+// pattern compiles the query honouring the case and regex toggles.
+func (s editorSearch) pattern() (*regexp.Regexp, error) {
+	q := s.query.Value()
+	if q == "" {
+		return nil, nil
+	}
+	if !s.regex {
+		q = regexp.QuoteMeta(q)
+	}
+	if !s.caseSensitive {
+		q = "(?i)" + q
+	}
+	return regexp.Compile(q)
+}
+
+// findMatches returns every non-empty match of re, line by line.
+func findMatches(re *regexp.Regexp, value string) []searchMatch {
+	var matches []searchMatch
+	for row, line := range strings.Split(value, "\n") {
+		for _, loc := range re.FindAllStringIndex(line, -1) {
+			if loc[0] == loc[1] {
+				continue
+			}
+			start := utf8.RuneCountInString(line[:loc[0]])
+			matches = append(matches, searchMatch{row: row, start: start, end: start + utf8.RuneCountInString(line[loc[0]:loc[1]])})
+		}
+	}
+	return matches
+}
+
+// NOTE: refreshSearch recomputes the matches and, when jump is set, selects the first one at or after the cursor.
+func (m model) refreshSearch(jump bool) model {
+	m.search.matches = nil
+	re, err := m.search.pattern()
+	m.search.err = err
+	if re == nil {
+		return m
+	}
+	m.search.matches = findMatches(re, m.editorContent.Value())
+	if len(m.search.matches) == 0 {
+		return m
+	}
+	m.search.current = min(m.search.current, len(m.search.matches)-1)
+	if jump {
+		row, col := m.editorCursor()
+		m.search.current = 0
+		for i, match := range m.search.matches {
+			if match.row > row || (match.row == row && match.start >= col) {
+				m.search.current = i
+				break
+			}
+		}
+		m = m.gotoMatch()
+	}
+	return m
+}
+
+// gotoMatch moves the editor cursor to the current match.
+func (m model) gotoMatch() model {
+	if len(m.search.matches) == 0 {
+		return m
+	}
+	match := m.search.matches[m.search.current]
+	setEditorCursor(&m.editorContent, match.row, match.start)
+	return m.followCursor()
+}
+
+// replaceInLine expands repl for the non-empty matches of re in line, the same ones findMatches reports. With only
+// >= 0, just the match starting at that rune offset is replaced. It returns the new line and how many were replaced.
+func replaceInLine(re *regexp.Regexp, line, repl string, only int) (string, int) {
+	var b strings.Builder
+	last, count := 0, 0
+	for _, loc := range re.FindAllStringSubmatchIndex(line, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if only >= 0 && utf8.RuneCountInString(line[:loc[0]]) != only {
+			continue
+		}
+		b.WriteString(line[last:loc[0]])
+		b.Write(re.ExpandString(nil, repl, line, loc))
+		last = loc[1]
+		count++
+	}
+	b.WriteString(line[last:])
+	return b.String(), count
+}
+
+// NOTE: replaceMatches replaces the current match, or every match when all is set, as a single undo step.
+func (m model) replaceMatches(all bool) (model, tea.Cmd) {
+	re, err := m.search.pattern()
+	if re == nil || err != nil || len(m.search.matches) == 0 {
+		return m, nil
+	}
+	m.editorHistory.record(snapshotEditor(m.editorContent), editOther, true, time.Now())
+
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	repl := m.search.replace.Value()
+	if !m.search.regex {
+		repl = strings.ReplaceAll(repl, "$", "$$")
+	}
+
+	count := 0
+	if all {
+		for i, line := range lines {
+			var n int
+			lines[i], n = replaceInLine(re, line, repl, -1)
+			count += n
+		}
+	} else {
+		match := m.search.matches[m.search.current]
+		lines[match.row], count = replaceInLine(re, lines[match.row], repl, match.start)
+	}
+
+	row, col := m.editorCursor()
+	m.editorContent.SetValue(strings.Join(lines, "\n"))
+	setEditorCursor(&m.editorContent, row, col)
+	m.editorRev++
+
+	m = m.refreshSearch(false)
+	if !all && len(m.search.matches) > 0 {
+		m = m.gotoMatch()
+	}
+	m.editorStatus = fmt.Sprintf("Replaced %d occurrence(s)", count)
+	return m.followCursor(), scheduleEditorTicks(m.editorRev)
+}
+
+// NOTE: updateSearch handles keys while the search bar is open.
+func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg.String() {
+	case "esc":
+		m.search.active = false
+		m.search.query.Blur()
+		m.search.replace.Blur()
+		return m, nil
+	case "ctrl+f":
+		m.search.replacing = false
+		m.search.replace.Blur()
+		return m, m.search.query.Focus()
+	case "tab":
+		m.search.replacing = !m.search.replacing
+		if m.search.replacing {
+			m.search.query.Blur()
+			return m, m.search.replace.Focus()
+		}
+		m.search.replace.Blur()
+		return m, m.search.query.Focus()
+	case "alt+c":
+		m.search.caseSensitive = !m.search.caseSensitive
+		return m.refreshSearch(true), nil
+	case "alt+r":
+		m.search.regex = !m.search.regex
+		return m.refreshSearch(true), nil
+	case "ctrl+a":
+		if m.search.replacing {
+			return m.replaceMatches(true)
+		}
+	case "enter":
+		if m.search.replacing {
+			return m.replaceMatches(false)
+		}
+		fallthrough
+	case "down", "ctrl+n":
+		if n := len(m.search.matches); n > 0 {
+			m.search.current = (m.search.current + 1) % n
+			m = m.gotoMatch()
+		}
+		return m, nil
+	case "up":
+		if n := len(m.search.matches); n > 0 {
+			m.search.current = (m.search.current - 1 + n) % n
+			m = m.gotoMatch()
+		}
+		return m, nil
+	}
+
+	if m.search.replacing {
+		m.search.replace, cmd = m.search.replace.Update(msg)
+		return m, cmd
+	}
+	before := m.search.query.Value()
+	m.search.query, cmd = m.search.query.Update(msg)
+	if m.search.query.Value() != before {
+		m = m.refreshSearch(true)
+	}
+	return m, cmd
+}
+
+// searchSpans highlights the matches on a line, with the current match stronger.
+func (m model) searchSpans(row int) []span {
+	if !m.search.active {
+		return nil
+	}
+	match := lipgloss.NewStyle().Background(m.theme.Border).Foreground(m.theme.Text)
+	current := lipgloss.NewStyle().Background(m.theme.Accent).Foreground(lipgloss.Color("230")).Bold(true)
+
+	var spans []span
+	for i, mt := range m.search.matches {
+		if mt.row != row {
+			continue
+		}
+		style := match
+		if i == m.search.current {
+			style = current
+		}
+		spans = append(spans, span{start: mt.start, end: mt.end, style: style})
+	}
+	return spans
+}
+
+// searchBar renders the find and replace inputs with the toggles and match count.
+func (m model) searchBar() string {
+	on := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+	off := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	toggle := func(label string, enabled bool) string {
+		if enabled {
+			return on.Render(label)
+		}
+		return off.Render(label)
+	}
+
+	var count string
+	switch {
+	case m.search.err != nil:
+		count = on.Render("invalid regex")
+	case m.search.query.Value() == "":
+		count = ""
+	case len(m.search.matches) == 0:
+		count = off.Render("no matches")
+	default:
+		count = off.Render(fmt.Sprintf("%d/%d", m.search.current+1, len(m.search.matches)))
+	}
+
+	find := lipgloss.JoinHorizontal(lipgloss.Center,
+		"  ", m.search.query.View(), "  ",
+		toggle("Aa", m.search.caseSensitive), " ", toggle(".*", m.search.regex), "  ", count)
+	hints := off.Render("  enter/↓ next  ↑ prev  alt+c case  alt+r regex  tab replace  esc close")
+	if m.search.replacing {
+		hints = off.Render("  enter replace  ctrl+a replace all  tab find  esc close")
+	}
+	return find + "\n  " + m.search.replace.View() + hints
+}
@@ -0,0 +1,531 @@
+// NOTE: Offline spell checking against a Hunspell-style .dic/.aff pair or a plain word list, plus a personal dictionary in the vault
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// spellChecker holds the known words. It is shared with preview rendering, which runs in the background.
+type spellChecker struct {
+	mu       sync.RWMutex
+	words    map[string]struct{}
+	personal map[string]struct{}
+}
+
+// speller is nil unless a dictionary is configured.
+var speller *spellChecker
+
+var (
+	// spellWordRe matches words, allowing inner apostrophes ("don't").
+	spellWordRe = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+	// spellSkipRe matches the parts of a line that are never checked: inline code, URLs, wikilinks, link targets and emails.
+	spellSkipRe = regexp.MustCompile("`[^`]*`|\\b(?:https?|ftp|file)://\\S+|\\bwww\\.\\S+|\\[\\[[^\\]]*\\]\\]|\\]\\([^)]*\\)|\\S+@\\S+\\.\\w+|<[^>]+>")
+)
+
+// personalDictionaryPath is the per-vault list of accepted words, one per line.
+func personalDictionaryPath() string {
+	return filepath.Join(vaultDir, ".dictionary")
+}
+
+// NOTE: loadSpellChecker loads cfg.Dictionary (relative paths are resolved against the vault) and the personal dictionary.
+// A .dic file is expanded with the .aff file next to it; anything else is read as a word list.
+func loadSpellChecker() error {
+	if cfg.Dictionary == "" {
+		return nil
+	}
+	path := cfg.Dictionary
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(vaultDir, path)
+	}
+
+	sc := &spellChecker{words: map[string]struct{}{}, personal: map[string]struct{}{}}
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".dic") {
+		err = sc.loadHunspell(path, strings.TrimSuffix(path, filepath.Ext(path))+".aff")
+	} else {
+		err = readWordList(path, sc.words)
+	}
+	if err != nil {
+		return err
+	}
+	if err := readWordList(personalDictionaryPath(), sc.personal); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	speller = sc
+	return nil
+}
+
+func readWordList(path string, into map[string]struct{}) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" && !strings.HasPrefix(w, "#") {
+			into[w] = struct{}{}
+		}
+	}
+	return scanner.Err()
+}
+
+// affixRule is one PFX or SFX line: strip this, add that, where the word matches cond.
+type affixRule struct {
+	strip string
+	add   string
+	cond  *regexp.Regexp
+}
+
+// affixClass is all the rules sharing a flag.
+type affixClass struct {
+	prefix bool
+	cross  bool
+	rules  []affixRule
+}
+
+// loadHunspell expands every dictionary entry with its affix flags. Only PFX, SFX and FLAG are understood,
+// which is enough for the common dictionaries; compounding and other options are ignored.
+func (sc *spellChecker) loadHunspell(dicPath, affPath string) error {
+	classes := map[string]*affixClass{}
+	flagMode := ""
+
+	if aff, err := os.Open(affPath); err == nil {
+		defer aff.Close()
+		scanner := bufio.NewScanner(aff)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) < 2 {
+				continue
+			}
+			switch fields[0] {
+			case "FLAG":
+				flagMode = fields[1]
+			case "PFX", "SFX":
+				if classes[fields[1]] == nil && len(fields) == 4 {
+					// Header: PFX flag cross count
+					classes[fields[1]] = &affixClass{prefix: fields[0] == "PFX", cross: fields[2] == "Y"}
+					continue
+				}
+				class := classes[fields[1]]
+				if class == nil || len(fields) < 4 {
+					continue
+				}
+				rule, err := parseAffixRule(class.prefix, fields[2], fields[3], fieldOr(fields, 4, "."))
+				if err != nil {
+					return fmt.Errorf("%s: %w", affPath, err)
+				}
+				class.rules = append(class.rules, rule)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	dic, err := os.Open(dicPath)
+	if err != nil {
+		return err
+	}
+	defer dic.Close()
+	scanner := bufio.NewScanner(dic)
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		// The first line is the entry count
+		if first {
+			first = false
+			if _, err := strconv.Atoi(line); err == nil {
+				continue
+			}
+		}
+		if line == "" {
+			continue
+		}
+		entry := strings.Fields(line)[0]
+		word, flags, _ := strings.Cut(entry, "/")
+		sc.words[word] = struct{}{}
+		sc.expand(word, splitFlags(flags, flagMode), classes)
+	}
+	return scanner.Err()
+}
+
+func fieldOr(fields []string, i int, def string) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return def
+}
+
+func parseAffixRule(prefix bool, strip, add, cond string) (affixRule, error) {
+	if strip == "0" {
+		strip = ""
+	}
+	// Continuation flags on the affix are not supported
+	add, _, _ = strings.Cut(add, "/")
+	if add == "0" {
+		add = ""
+	}
+	pattern := cond + "$"
+	if prefix {
+		pattern = "^" + cond
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return affixRule{}, fmt.Errorf("bad affix condition %q: %w", cond, err)
+	}
+	return affixRule{strip: strip, add: add, cond: re}, nil
+}
+
+// splitFlags splits an entry's flags according to the .aff FLAG setting.
+func splitFlags(flags, mode string) []string {
+	if flags == "" {
+		return nil
+	}
+	switch mode {
+	case "long":
+		var out []string
+		r := []rune(flags)
+		for i := 0; i+1 < len(r); i += 2 {
+			out = append(out, string(r[i:i+2]))
+		}
+		return out
+	case "num":
+		return strings.Split(flags, ",")
+	default:
+		var out []string
+		for _, r := range flags {
+			out = append(out, string(r))
+		}
+		return out
+	}
+}
+
+// expand adds the word's affixed forms, combining prefixes and suffixes that allow cross products.
+func (sc *spellChecker) expand(word string, flags []string, classes map[string]*affixClass) {
+	var suffixed []string
+	for _, f := range flags {
+		class := classes[f]
+		if class == nil || class.prefix {
+			continue
+		}
+		for _, r := range class.rules {
+			if strings.HasSuffix(word, r.strip) && r.cond.MatchString(word) {
+				form := strings.TrimSuffix(word, r.strip) + r.add
+				sc.words[form] = struct{}{}
+				if class.cross {
+					suffixed = append(suffixed, form)
+				}
+			}
+		}
+	}
+	for _, f := range flags {
+		class := classes[f]
+		if class == nil || !class.prefix {
+			continue
+		}
+		for _, r := range class.rules {
+			if strings.HasPrefix(word, r.strip) && r.cond.MatchString(word) {
+				sc.words[r.add+strings.TrimPrefix(word, r.strip)] = struct{}{}
+				if class.cross {
+					for _, s := range suffixed {
+						sc.words[r.add+strings.TrimPrefix(s, r.strip)] = struct{}{}
+					}
+				}
+			}
+		}
+	}
+}
+
+// known accepts a word as written, lowercased, or with only its first letter lowered (for words starting a sentence).
+func (sc *spellChecker) known(word string) bool {
+	word = strings.ReplaceAll(word, "’", "'")
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	candidates := []string{word, strings.ToLower(word)}
+	if r, size := utf8.DecodeRuneInString(word); size > 0 {
+		candidates = append(candidates, string(unicode.ToLower(r))+word[size:])
+	}
+	for _, w := range candidates {
+		if _, ok := sc.words[w]; ok {
+			return true
+		}
+		if _, ok := sc.personal[w]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// checkable skips single letters, acronyms and words glued to digits or underscores (identifiers).
+func checkable(line string, start, end int) bool {
+	word := line[start:end]
+	if utf8.RuneCountInString(word) < 2 || strings.ToUpper(word) == word {
+		return false
+	}
+	if r, _ := utf8.DecodeLastRuneInString(line[:start]); start > 0 && (unicode.IsDigit(r) || r == '_') {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(line[end:]); end < len(line) && (unicode.IsDigit(r) || r == '_') {
+		return false
+	}
+	return true
+}
+
+// NOTE: check returns the misspelled words in lines, skipping fenced code blocks and the spellSkipRe regions.
+func (sc *spellChecker) check(lines []string) []searchMatch {
+	var out []searchMatch
+	inFence := false
+	for row, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		skips := spellSkipRe.FindAllStringIndex(line, -1)
+		for _, loc := range spellWordRe.FindAllStringIndex(line, -1) {
+			skipped := false
+			for _, s := range skips {
+				if loc[0] < s[1] && loc[1] > s[0] {
+					skipped = true
+					break
+				}
+			}
+			if skipped || !checkable(line, loc[0], loc[1]) || sc.known(line[loc[0]:loc[1]]) {
+				continue
+			}
+			start := utf8.RuneCountInString(line[:loc[0]])
+			out = append(out, searchMatch{row: row, start: start, end: start + utf8.RuneCountInString(line[loc[0]:loc[1]])})
+		}
+	}
+	return out
+}
+
+// editDistance is the Damerau-Levenshtein (optimal string alignment) distance between two words.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+// NOTE: suggest returns up to limit dictionary words within two edits of word, closest first, matching its capitalisation.
+func (sc *spellChecker) suggest(word string, limit int) []string {
+	lower := []rune(strings.ToLower(word))
+	type candidate struct {
+		word string
+		dist int
+	}
+	var found []candidate
+
+	sc.mu.RLock()
+	for _, dict := range []map[string]struct{}{sc.words, sc.personal} {
+		for w := range dict {
+			r := []rune(strings.ToLower(w))
+			if n := len(r) - len(lower); n > 2 || n < -2 {
+				continue
+			}
+			if d := editDistance(lower, r); d <= 2 {
+				found = append(found, candidate{w, d})
+			}
+		}
+	}
+	sc.mu.RUnlock()
+
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].dist != found[j].dist {
+			return found[i].dist < found[j].dist
+		}
+		return found[i].word < found[j].word
+	})
+
+	first, _ := utf8.DecodeRuneInString(word)
+	capitalised := unicode.IsUpper(first)
+
+	seen := map[string]bool{}
+	var out []string
+	for _, c := range found {
+		w := c.word
+		if capitalised {
+			r, n := utf8.DecodeRuneInString(w)
+			w = string(unicode.ToUpper(r)) + w[n:]
+		}
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		out = append(out, w)
+		if len(out) == limit {
+			break
+		}
+	}
+	return out
+}
+
+// addWord accepts a word from now on and appends it to the personal dictionary.
+func (sc *spellChecker) addWord(word string) error {
+	sc.mu.Lock()
+	sc.personal[word] = struct{}{}
+	sc.mu.Unlock()
+
+	f, err := os.OpenFile(personalDictionaryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, word)
+	return err
+}
+
+// Misspelled words are marked in the preview source with these private use characters, which pass through glamour
+// untouched, and the marks become underlines once the note is rendered.
+const (
+	spellMarkStart = "\ue000"
+	spellMarkEnd   = "\ue001"
+)
+
+// NOTE: markMisspelled brackets exactly the words check flags in a note's source, so code, URLs and other skipped
+// regions (and the same letters inside longer words) are never underlined in the preview.
+func markMisspelled(source string) string {
+	if speller == nil || !cfg.SpellPreview {
+		return source
+	}
+	lines := strings.Split(source, "\n")
+	matches := speller.check(lines)
+	// Mark from the end of each line so earlier offsets stay valid
+	for i := len(matches) - 1; i >= 0; i-- {
+		mt := matches[i]
+		// Indented code renders verbatim, like fenced code, so leave it alone as embedImages does
+		if strings.HasPrefix(lines[mt.row], "    ") || strings.HasPrefix(lines[mt.row], "\t") {
+			continue
+		}
+		runes := []rune(lines[mt.row])
+		lines[mt.row] = string(runes[:mt.start]) + spellMarkStart + string(runes[mt.start:mt.end]) + spellMarkEnd + string(runes[mt.end:])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// underlineMarked turns the marks left by markMisspelled in rendered output into underlines.
+func underlineMarked(rendered string) string {
+	return strings.NewReplacer(spellMarkStart, "\x1b[4m", spellMarkEnd, "\x1b[24m").Replace(rendered)
+}
+
+// spellSpans underlines misspelled words, grouped by line.
+func (m model) spellSpans(lines []string) [][]span {
+	out := make([][]span, len(lines))
+	if speller == nil {
+		return out
+	}
+	style := lipgloss.NewStyle().Underline(true).Foreground(m.theme.Accent)
+	for _, mt := range speller.check(lines) {
+		out[mt.row] = append(out[mt.row], span{start: mt.start, end: mt.end, style: style})
+	}
+	return out
+}
+
+// spellMenu is the suggestion prompt for the word under the cursor.
+type spellMenu struct {
+	active      bool
+	word        searchMatch
+	text        string
+	suggestions []string
+}
+
+// NOTE: openSpellMenu looks up the word under the cursor and offers suggestions, or reports that it is spelled correctly.
+func (m model) openSpellMenu() (tea.Model, tea.Cmd) {
+	if speller == nil {
+		m.editorStatus = "Spell checking is off: set \"dictionary\" in .config.json"
+		return m, nil
+	}
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	row, col := m.editorCursor()
+	for _, mt := range speller.check(lines) {
+		if mt.row == row && col >= mt.start && col <= mt.end {
+			text := string([]rune(lines[row])[mt.start:mt.end])
+			m.spellMenu = spellMenu{active: true, word: mt, text: text, suggestions: speller.suggest(text, 9)}
+			return m, nil
+		}
+	}
+	m.editorStatus = "No misspelling under the cursor"
+	return m, nil
+}
+
+// NOTE: updateSpellMenu replaces the word with a numbered suggestion, adds it to the personal dictionary with a, or closes the menu.
+func (m model) updateSpellMenu(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	menu := m.spellMenu
+	m.spellMenu = spellMenu{}
+
+	key := msg.String()
+	if key == "a" {
+		if err := speller.addWord(menu.text); err != nil {
+			m.editorStatus = "Could not add word: " + err.Error()
+			return m, nil
+		}
+		m.editorStatus = fmt.Sprintf("Added %q to the dictionary", menu.text)
+		// The text is unchanged, so drop the cached spans to clear the underline
+		*m.spanCache = spanCache{rev: -1}
+		return m, nil
+	}
+	n, err := strconv.Atoi(key)
+	if err != nil || n < 1 || n > len(menu.suggestions) {
+		return m, nil
+	}
+
+	lines := strings.Split(m.editorContent.Value(), "\n")
+	runes := []rune(lines[menu.word.row])
+	fix := menu.suggestions[n-1]
+	lines[menu.word.row] = string(runes[:menu.word.start]) + fix + string(runes[menu.word.end:])
+	m, cmd := m.editBuffer(strings.Join(lines, "\n"), menu.word.row, menu.word.start+utf8.RuneCountInString(fix))
+	return m, cmd
+}
+
+// spellMenuView lists the suggestions in the editor header.
+func (m model) spellMenuView() string {
+	accent := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+	muted := lipgloss.NewStyle().Foreground(m.theme.Secondary)
+
+	var items []string
+	for i, s := range m.spellMenu.suggestions {
+		items = append(items, fmt.Sprintf("%d %s", i+1, s))
+	}
+	if len(items) == 0 {
+		items = append(items, "no suggestions")
+	}
+	return accent.MarginLeft(2).Render(m.spellMenu.text+":") + muted.Render(" "+strings.Join(items, "  ")+"  (a)dd  (esc)")
+}
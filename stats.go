@@ -0,0 +1,156 @@
+// NOTE: Editor status line and writing goals. Word counts per note and day are kept in <vault>/.stats/words.json
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// wordsPerMinute is the reading speed used for the reading time estimate.
+const wordsPerMinute = 200
+
+// wordStats maps a day ("2006-01-02") to the word count of each note saved that day, keyed by vault-relative path.
+type wordStats map[string]map[string]int
+
+func wordStatsPath() string {
+	return filepath.Join(vaultDir, ".stats", "words.json")
+}
+
+// loadWordStats reads the history; a missing file starts an empty one. On any other error the stats are nil, so
+// recordWordCount won't overwrite a history it couldn't read.
+func loadWordStats() (wordStats, error) {
+	stats := wordStats{}
+	data, err := os.ReadFile(wordStatsPath())
+	if os.IsNotExist(err) {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil, fmt.Errorf("%s: %w", wordStatsPath(), err)
+	}
+	return stats, nil
+}
+
+// NOTE: recordWordCount stores the word count of a saved note against today and writes the history back.
+func (s wordStats) recordWordCount(rel string, words int, now time.Time) error {
+	if s == nil {
+		return fmt.Errorf("%s could not be read, so it was left as it is", wordStatsPath())
+	}
+	day := now.Format("2006-01-02")
+	if s[day] == nil {
+		s[day] = map[string]int{}
+	}
+	s[day][rel] = words
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(wordStatsPath()), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(wordStatsPath(), data, 0o644)
+}
+
+// goalMode is the journal folder a note belongs to ("daily", "weekly", ...), or "all" for notes at the vault root.
+func goalMode(rel string) string {
+	dir := filepath.Dir(rel)
+	if dir == "." {
+		return "all"
+	}
+	return strings.Split(filepath.ToSlash(dir), "/")[0]
+}
+
+// streak counts the consecutive days, ending today, on which a note in mode reached goal.
+// Today only counts once it is reached, so an unfinished today does not break the streak.
+func (s wordStats) streak(mode string, goal int, now time.Time) int {
+	met := func(day time.Time) bool {
+		for rel, words := range s[day.Format("2006-01-02")] {
+			if goalMode(rel) == mode && words >= goal {
+				return true
+			}
+		}
+		return false
+	}
+
+	day := now
+	if !met(day) {
+		day = day.AddDate(0, 0, -1)
+	}
+	n := 0
+	for ; met(day); day = day.AddDate(0, 0, -1) {
+		n++
+	}
+	return n
+}
+
+// editorRelPath is the edited file relative to the vault.
+func (m model) editorRelPath() string {
+	rel, err := filepath.Rel(vaultDir, m.editorFile)
+	if err != nil {
+		return filepath.Base(m.editorFile)
+	}
+	return rel
+}
+
+// NOTE: editorStatusLine shows the cursor position, counts, reading time, the dirty marker and progress toward the word goal.
+func (m model) editorStatusLine() string {
+	value := m.editorContent.Value()
+	words := len(strings.Fields(value))
+	row, col := m.editorCursor()
+
+	muted := lipgloss.NewStyle().Foreground(m.theme.Muted)
+	accent := lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true)
+
+	parts := []string{
+		fmt.Sprintf("Ln %d, Col %d", row+1, col+1),
+		fmt.Sprintf("%d words", words),
+		fmt.Sprintf("%d chars", utf8.RuneCountInString(value)),
+		fmt.Sprintf("%d min read", max(1, (words+wordsPerMinute-1)/wordsPerMinute)),
+	}
+	left := "  " + muted.Render(strings.Join(parts, " · "))
+	if m.editorDirty() {
+		left += accent.Render("  ● modified")
+	}
+
+	mode := goalMode(m.editorRelPath())
+	goal := cfg.WordGoals[mode]
+	if goal <= 0 {
+		return left
+	}
+
+	// Count the unsaved buffer towards today so progress and the streak update while typing
+	stats := wordStats{}
+	for day, notes := range m.wordStats {
+		stats[day] = notes
+	}
+	today := time.Now().Format("2006-01-02")
+	stats[today] = map[string]int{}
+	for rel, n := range m.wordStats[today] {
+		stats[today][rel] = n
+	}
+	stats[today][m.editorRelPath()] = words
+
+	progress := fmt.Sprintf("Goal %d/%d (%d%%)", words, goal, min(100, words*100/goal))
+	style := muted
+	if words >= goal {
+		style = lipgloss.NewStyle().Foreground(m.theme.Primary).Bold(true)
+	}
+	right := style.Render(progress)
+	if n := stats.streak(mode, goal, time.Now()); n > 0 {
+		right += muted.Render(fmt.Sprintf(" · %d-day streak", n))
+	}
+
+	gap := max(2, m.width-lipgloss.Width(left)-lipgloss.Width(right)-2)
+	return left + strings.Repeat(" ", gap) + right
+}
@@ -0,0 +1,510 @@
+// NOTE: Structured previews: CSV/TSV files as aligned tables, JSON and YAML as trees folded by level (+ and -) or node by node (ctrl+e)
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/lipgloss/table"
+	"github.com/charmbracelet/x/ansi"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTreeDepth expands the document and its top-level values; anything deeper starts folded.
+const defaultTreeDepth = 2
+
+// maxCellWidth keeps one long cell from pushing every other column off screen.
+const maxCellWidth = 40
+
+// NOTE: structuredPreview renders CSV, TSV, JSON and YAML files. ok is false for any other file.
+// A document that doesn't parse shows the error above its source, with the offending line marked.
+func structuredPreview(ext, content string, opts previewOptions) (fileLoadedMsg, bool) {
+	var (
+		rendered string
+		docs     []*treeNode
+		language string
+		line     int
+		err      error
+	)
+	switch ext {
+	case ".csv", ".tsv":
+		comma, name := ',', "CSV"
+		if ext == ".tsv" {
+			comma, name = '\t', "TSV"
+		}
+		language = name
+		rendered, line, err = renderTable(content, comma, opts)
+	case ".json":
+		language = "JSON"
+		var root *treeNode
+		root, line, err = parseJSONTree(content)
+		if err == nil {
+			docs = []*treeNode{root}
+		}
+	case ".yaml", ".yml":
+		language = "YAML"
+		docs, line, err = parseYAMLTree(content)
+	default:
+		return fileLoadedMsg{}, false
+	}
+
+	if err != nil {
+		return fileLoadedMsg{content: parseErrorView(err, line, content, opts.theme), language: language}, true
+	}
+	if len(docs) > 0 {
+		// Trees are drawn by the model, which keeps them to fold without reading the file again
+		foldToDepth(docs, opts.treeDepth)
+		return fileLoadedMsg{language: language, tree: docs}, true
+	}
+	if rendered == "" {
+		rendered = lipgloss.NewStyle().Foreground(opts.theme.Muted).Render("(empty)")
+	}
+	return fileLoadedMsg{content: rendered, language: language}, true
+}
+
+// NOTE: renderTable lays out delimited data under its header row, with rows of different lengths padded out.
+// The table is squeezed to the preview width only when it doesn't fit. line is where a parse error happened.
+func renderTable(content string, comma rune, opts previewOptions) (string, int, error) {
+	r := csv.NewReader(strings.NewReader(content))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	// TSV has no quoting rules of its own, so quotes are taken literally
+	r.LazyQuotes = comma == '\t'
+	records, err := r.ReadAll()
+	if err != nil {
+		var pe *csv.ParseError
+		if errors.As(err, &pe) {
+			return "", pe.Line, err
+		}
+		return "", 0, err
+	}
+	if len(records) == 0 {
+		return lipgloss.NewStyle().Foreground(opts.theme.Muted).Render("(empty)"), 0, nil
+	}
+
+	cols := 0
+	for _, rec := range records {
+		cols = max(cols, len(rec))
+	}
+	for i, rec := range records {
+		for j, cell := range rec {
+			rec[j] = ansi.Truncate(strings.ReplaceAll(cell, "\n", " "), maxCellWidth, "…")
+		}
+		records[i] = append(rec, make([]string, cols-len(rec))...)
+	}
+
+	t := table.New().
+		Border(lipgloss.RoundedBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(opts.theme.Border)).
+		Headers(records[0]...).
+		Rows(records[1:]...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			s := lipgloss.NewStyle().Padding(0, 1)
+			if row == table.HeaderRow {
+				return s.Foreground(opts.theme.Primary).Bold(true)
+			}
+			return s.Foreground(opts.theme.Text)
+		})
+	out := t.Render()
+	if lipgloss.Width(out) > opts.width {
+		out = t.Width(opts.width).Render()
+	}
+	summary := fmt.Sprintf("%d rows × %d columns", len(records)-1, cols)
+	if len(records) == 2 {
+		summary = fmt.Sprintf("1 row × %d columns", cols)
+	}
+	return out + "\n" + lipgloss.NewStyle().Foreground(opts.theme.Muted).Render(summary), 0, nil
+}
+
+type treeKind int
+
+const (
+	treeScalar treeKind = iota
+	treeObject
+	treeArray
+)
+
+// treeNode is one value of a JSON or YAML document. Scalars keep their text and a style hint: "string", "number",
+// "bool", "null" or "alias". folded hides the children of an object or array.
+type treeNode struct {
+	key      string
+	kind     treeKind
+	value    string
+	hint     string
+	children []*treeNode
+	folded   bool
+}
+
+// NOTE: parseJSONTree reads a JSON document token by token, so object keys keep their order. Errors name the line
+// and column where the decoder stopped, since encoding/json only reports a byte offset.
+func parseJSONTree(content string) (*treeNode, int, error) {
+	dec := json.NewDecoder(strings.NewReader(content))
+	dec.UseNumber()
+	locate := func(err error) (int, error) {
+		offset := dec.InputOffset()
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			offset = se.Offset
+		}
+		before := content[:min(int(offset), len(content))]
+		line := strings.Count(before, "\n") + 1
+		col := len([]rune(before[strings.LastIndex(before, "\n")+1:])) + 1
+		return line, fmt.Errorf("line %d, column %d: %w", line, col, err)
+	}
+
+	root, err := parseJSONValue(dec)
+	if errors.Is(err, io.EOF) {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		line, err := locate(err)
+		return nil, line, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		line, err := locate(errors.New("unexpected data after the top-level value"))
+		return nil, line, err
+	}
+	return root, 0, nil
+}
+
+func parseJSONValue(dec *json.Decoder) (*treeNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		node := &treeNode{kind: treeObject}
+		if t == '[' {
+			node.kind = treeArray
+		}
+		for i := 0; dec.More(); i++ {
+			key := strconv.Itoa(i)
+			if node.kind == treeObject {
+				keyTok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ = keyTok.(string)
+			}
+			child, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			child.key = key
+			node.children = append(node.children, child)
+		}
+		// The closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &treeNode{value: strconv.Quote(t), hint: "string"}, nil
+	case json.Number:
+		return &treeNode{value: t.String(), hint: "number"}, nil
+	case bool:
+		return &treeNode{value: strconv.FormatBool(t), hint: "bool"}, nil
+	}
+	return &treeNode{value: "null", hint: "null"}, nil
+}
+
+// yamlLineRe finds the line number in yaml.v3's error messages, e.g. "yaml: line 3: mapping values are not allowed".
+var yamlLineRe = regexp.MustCompile(`line (\d+)`)
+
+// parseYAMLTree reads every document in a YAML stream.
+func parseYAMLTree(content string) ([]*treeNode, int, error) {
+	dec := yaml.NewDecoder(strings.NewReader(content))
+	var docs []*treeNode
+	for {
+		var n yaml.Node
+		err := dec.Decode(&n)
+		if errors.Is(err, io.EOF) {
+			return docs, 0, nil
+		}
+		if err != nil {
+			line := 0
+			if sm := yamlLineRe.FindStringSubmatch(err.Error()); sm != nil {
+				line, _ = strconv.Atoi(sm[1])
+			}
+			return nil, line, err
+		}
+		docs = append(docs, yamlTree(&n))
+	}
+}
+
+func yamlTree(n *yaml.Node) *treeNode {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return &treeNode{value: "null", hint: "null"}
+		}
+		return yamlTree(n.Content[0])
+	case yaml.MappingNode:
+		node := &treeNode{kind: treeObject}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			child := yamlTree(n.Content[i+1])
+			child.key = n.Content[i].Value
+			node.children = append(node.children, child)
+		}
+		return node
+	case yaml.SequenceNode:
+		node := &treeNode{kind: treeArray}
+		for i, c := range n.Content {
+			child := yamlTree(c)
+			child.key = strconv.Itoa(i)
+			node.children = append(node.children, child)
+		}
+		return node
+	case yaml.AliasNode:
+		return &treeNode{value: "*" + n.Value, hint: "alias"}
+	}
+
+	switch n.ShortTag() {
+	case "!!int", "!!float":
+		return &treeNode{value: n.Value, hint: "number"}
+	case "!!bool":
+		return &treeNode{value: n.Value, hint: "bool"}
+	case "!!null":
+		return &treeNode{value: "null", hint: "null"}
+	}
+	return &treeNode{value: n.Value, hint: "string"}
+}
+
+// countLabel is the size shown next to an object or array, folded or not.
+func (n *treeNode) countLabel() string {
+	if n.kind == treeArray {
+		if len(n.children) == 1 {
+			return "[1 item]"
+		}
+		return fmt.Sprintf("[%d items]", len(n.children))
+	}
+	if len(n.children) == 1 {
+		return "{1 key}"
+	}
+	return fmt.Sprintf("{%d keys}", len(n.children))
+}
+
+// foldable reports whether a node has children to hide.
+func (n *treeNode) foldable() bool {
+	return n != nil && n.kind != treeScalar && len(n.children) > 0
+}
+
+// foldToDepth folds every object and array at depth or deeper and unfolds the rest.
+func foldToDepth(docs []*treeNode, depth int) {
+	var walk func(n *treeNode, level int)
+	walk = func(n *treeNode, level int) {
+		n.folded = n.foldable() && level >= depth
+		for _, c := range n.children {
+			walk(c, level+1)
+		}
+	}
+	for _, doc := range docs {
+		walk(doc, 0)
+	}
+}
+
+// NOTE: renderTree draws documents as an indented tree, one line per visible node cut to width, with folded objects
+// and arrays reduced to their size. rows gives the node on each line (nil for the "---" between YAML documents), and
+// the selected node is highlighted.
+func renderTree(docs []*treeNode, th Theme, width int, selected *treeNode) (string, []*treeNode) {
+	muted := lipgloss.NewStyle().Foreground(th.Muted)
+	keyStyle := lipgloss.NewStyle().Foreground(th.Primary)
+	cursor := lipgloss.NewStyle().Foreground(th.Accent).Bold(true)
+	values := map[string]lipgloss.Style{
+		"string": lipgloss.NewStyle().Foreground(th.Secondary),
+		"number": lipgloss.NewStyle().Foreground(th.Accent),
+		"bool":   lipgloss.NewStyle().Foreground(th.Accent),
+		"null":   muted.Italic(true),
+		"alias":  muted,
+	}
+
+	var lines []string
+	var rows []*treeNode
+	add := func(n *treeNode, line string) {
+		line = ansi.Truncate(line, max(1, width), "…")
+		if n != nil && n == selected {
+			line = cursor.Render(ansi.Strip(line))
+		}
+		lines = append(lines, line)
+		rows = append(rows, n)
+	}
+	var walk func(n *treeNode, level int)
+	walk = func(n *treeNode, level int) {
+		indent := strings.Repeat("  ", level)
+		label := ""
+		if n.key != "" {
+			label = keyStyle.Render(n.key) + muted.Render(": ")
+		}
+		if n.kind == treeScalar {
+			add(n, indent+"  "+label+values[n.hint].Render(n.value))
+			return
+		}
+		marker := "▾ "
+		if n.folded {
+			marker = "▸ "
+		}
+		add(n, indent+muted.Render(marker)+label+muted.Render(n.countLabel()))
+		if n.folded {
+			return
+		}
+		for _, c := range n.children {
+			walk(c, level+1)
+		}
+	}
+
+	for i, doc := range docs {
+		if i > 0 {
+			add(nil, muted.Render("---"))
+		}
+		walk(doc, 0)
+	}
+	if len(lines) == 0 {
+		return muted.Render("(empty)"), nil
+	}
+	return strings.Join(lines, "\n"), rows
+}
+
+// parseErrorView shows a parse error above the document's source, marking the line it points at.
+func parseErrorView(err error, line int, content string, th Theme) string {
+	errStyle := lipgloss.NewStyle().Foreground(th.Accent).Bold(true)
+	muted := lipgloss.NewStyle().Foreground(th.Muted)
+
+	// encoding/csv and yaml.v3 prefix their own names; every message here should read "Parse error at line N: ..."
+	text := strings.TrimPrefix(strings.TrimPrefix(err.Error(), "yaml: "), "parse error on ")
+	msg := "Parse error: " + text
+	if strings.HasPrefix(text, "line ") {
+		msg = "Parse error at " + text
+	} else if line > 0 {
+		msg = fmt.Sprintf("Parse error at line %d: %s", line, text)
+	}
+	out := []string{errStyle.Render(msg), ""}
+
+	src := strings.Split(strings.ReplaceAll(strings.TrimSuffix(content, "\n"), "\t", "    "), "\n")
+	width := len(fmt.Sprint(len(src)))
+	for i, l := range src {
+		gutter := fmt.Sprintf("  %*d │ ", width, i+1)
+		if i+1 == line {
+			out = append(out, errStyle.Render(fmt.Sprintf("› %*d │ ", width, i+1))+errStyle.Render(l))
+			continue
+		}
+		out = append(out, muted.Render(gutter)+l)
+	}
+	return strings.Join(out, "\n")
+}
+
+// NOTE: redrawTree renders the previewed tree into the viewport, keeping the scroll position and, while the tree has
+// focus, the cursor on the same node.
+func (m model) redrawTree() model {
+	var selected *treeNode
+	if m.treeFocus && m.treeCursor < len(m.treeRows) {
+		selected = m.treeRows[m.treeCursor]
+	}
+	content, rows := renderTree(m.previewTree, m.theme, m.viewport.Width-1, selected)
+	offset := m.viewport.YOffset
+	m.viewport.SetContent(content)
+	m.viewport.SetYOffset(offset)
+
+	m.treeRows = rows
+	m.treeCursor = max(0, min(m.treeCursor, len(rows)-1))
+	for i, n := range rows {
+		if n != nil && n == selected {
+			m.treeCursor = i
+		}
+	}
+	return m
+}
+
+// NOTE: foldTree expands (delta 1) or collapses (delta -1) the whole JSON or YAML preview to one level more or less.
+// The tree is kept on the model, so only the view is redrawn.
+func (m model) foldTree(delta int) (tea.Model, tea.Cmd) {
+	m.treeDepth = max(1, min(32, m.treeDepth+delta))
+	foldToDepth(m.previewTree, m.treeDepth)
+	m = m.redrawTree()
+	if m.treeFocus {
+		m = m.followTreeCursor()
+	}
+	return m, m.list.NewStatusMessage(fmt.Sprintf("Tree depth: %d", m.treeDepth))
+}
+
+// NOTE: openTree gives the tree preview a cursor for folding single objects and arrays.
+func (m model) openTree() (tea.Model, tea.Cmd) {
+	if !m.showPreview || len(m.treeRows) == 0 {
+		return m, m.list.NewStatusMessage("Nothing to fold in this preview")
+	}
+	m.treeFocus = true
+	// Start on the first node on screen
+	m.treeCursor = min(m.viewport.YOffset, len(m.treeRows)-1)
+	for m.treeCursor < len(m.treeRows)-1 && m.treeRows[m.treeCursor] == nil {
+		m.treeCursor++
+	}
+	m = m.redrawTree()
+	return m, m.list.NewStatusMessage("↑/↓ move · enter fold · +/- all · esc done")
+}
+
+// NOTE: updateTree moves the tree cursor and folds or unfolds the node under it.
+func (m model) updateTree(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	move := func(to, step int) {
+		for to >= 0 && to < len(m.treeRows) && m.treeRows[to] == nil {
+			to += step
+		}
+		if to >= 0 && to < len(m.treeRows) {
+			m.treeCursor = to
+		}
+	}
+	node := m.treeRows[m.treeCursor]
+
+	switch msg.String() {
+	case "up", "k":
+		move(m.treeCursor-1, -1)
+	case "down", "j":
+		move(m.treeCursor+1, 1)
+	case "home", "g":
+		move(0, 1)
+	case "end", "G":
+		move(len(m.treeRows)-1, -1)
+	case "enter", " ":
+		if node.foldable() {
+			node.folded = !node.folded
+		}
+	case "right", "l":
+		if node.foldable() {
+			node.folded = false
+		}
+	case "left", "h":
+		if node.foldable() {
+			node.folded = true
+		}
+	case "+", "=":
+		return m.foldTree(1)
+	case "-":
+		return m.foldTree(-1)
+	case "esc", "q", "ctrl+e":
+		m.treeFocus = false
+		return m.redrawTree(), nil
+	case "ctrl+c":
+		return m, tea.Quit
+	default:
+		return m, nil
+	}
+	return m.redrawTree().followTreeCursor(), nil
+}
+
+// followTreeCursor scrolls the preview just enough to keep the cursor's line in view.
+func (m model) followTreeCursor() model {
+	if m.treeCursor < m.viewport.YOffset {
+		m.viewport.SetYOffset(m.treeCursor)
+	} else if m.treeCursor >= m.viewport.YOffset+m.viewport.Height {
+		m.viewport.SetYOffset(m.treeCursor - m.viewport.Height + 1)
+	}
+	return m
+}
@@ -2,7 +2,11 @@
 
 package main
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 type Theme struct {
 	Primary   lipgloss.Color
@@ -13,6 +17,7 @@ type Theme struct {
 	MoreMuted lipgloss.Color
 	Text      lipgloss.Color
 	SubText   lipgloss.Color
+	Light     bool // for light terminal backgrounds
 }
 
 var themes = map[string]Theme{
@@ -140,6 +145,17 @@ var themes = map[string]Theme{
 		Text:      lipgloss.Color("255"), // bright white
 		SubText:   lipgloss.Color("182"), // light lavender
 	},
+	"light": {
+		Primary:   lipgloss.Color("25"),  // blue
+		Secondary: lipgloss.Color("66"),  // slate
+		Border:    lipgloss.Color("250"), // light gray
+		Accent:    lipgloss.Color("161"), // magenta
+		Muted:     lipgloss.Color("244"),
+		MoreMuted: lipgloss.Color("252"),
+		Text:      lipgloss.Color("235"), // near black
+		SubText:   lipgloss.Color("240"),
+		Light:     true,
+	},
 	"algae": {
 		Primary:   lipgloss.Color("107"), // #628141 green
 		Secondary: lipgloss.Color("252"), // #E5D9B6 cream
@@ -152,6 +168,24 @@ var themes = map[string]Theme{
 	},
 }
 
+// themeNames lists the themes in a stable order for cycling.
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// knownTheme returns name if it is a known theme, otherwise "default".
+func knownTheme(name string) string {
+	if _, ok := themes[name]; ok {
+		return name
+	}
+	return "default"
+}
+
 func getTheme(name string) Theme {
 	if t, ok := themes[name]; ok {
 		return t
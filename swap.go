@@ -0,0 +1,213 @@
+// NOTE: Swap files and crash recovery for the inbuilt editor
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// swapDelay is how long the editor waits after the last change before writing the swap file.
+const swapDelay = time.Second
+
+type (
+	swapTickMsg     struct{ rev int }
+	autosaveTickMsg struct{ rev int }
+)
+
+// swapPath returns the hidden swap file kept next to a note, e.g. daily/.2026-02-18.md.swp
+func swapPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".swp")
+}
+
+// notePathForSwap is the inverse of swapPath.
+func notePathForSwap(swap string) string {
+	base := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(swap), "."), ".swp")
+	return filepath.Join(filepath.Dir(swap), base)
+}
+
+// writeSwap runs synchronously in Update: an async write could land after removeSwap and leave a stale swap file.
+func writeSwap(path, content string) {
+	os.WriteFile(swapPath(path), []byte(content), 0o600)
+}
+
+func removeSwap(path string) {
+	os.Remove(swapPath(path))
+}
+
+// scheduleEditorTicks starts the idle timers for the swap file, autosave and live preview after a change.
+func scheduleEditorTicks(rev int) tea.Cmd {
+	cmds := []tea.Cmd{
+		tea.Tick(swapDelay, func(time.Time) tea.Msg { return swapTickMsg{rev: rev} }),
+		tea.Tick(editorPreviewDelay, func(time.Time) tea.Msg { return editorPreviewTickMsg{rev: rev} }),
+	}
+	if cfg.Autosave > 0 {
+		cmds = append(cmds, tea.Tick(time.Duration(cfg.Autosave), func(time.Time) tea.Msg { return autosaveTickMsg{rev: rev} }))
+	}
+	return tea.Batch(cmds...)
+}
+
+// findRecoverableSwaps returns swap files that are newer than their note (or whose note is gone).
+func findRecoverableSwaps() []string {
+	var swaps []string
+	filepath.WalkDir(vaultDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if path != vaultDir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasPrefix(d.Name(), ".") || !strings.HasSuffix(d.Name(), ".swp") {
+			return nil
+		}
+		swapInfo, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		noteInfo, err := os.Stat(notePathForSwap(path))
+		if err != nil || swapInfo.ModTime().After(noteInfo.ModTime()) {
+			swaps = append(swaps, path)
+		}
+		return nil
+	})
+	return swaps
+}
+
+// diffLine is one line of a line-based diff.
+type diffLine struct {
+	op   byte // ' ', '+' or '-'
+	text string
+}
+
+// lineDiff computes a minimal line diff from a to b using a longest common subsequence table.
+func lineDiff(a, b []string) []diffLine {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []diffLine
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffLine{'-', a[i]})
+			i++
+		default:
+			out = append(out, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, diffLine{'+', b[j]})
+	}
+	return out
+}
+
+// NOTE: recoverSwap opens the note in the inbuilt editor with the swap contents loaded as unsaved changes.
+func (m model) recoverSwap(swap string) (tea.Model, tea.Cmd) {
+	data, err := os.ReadFile(swap)
+	if err != nil {
+		return m.skipSwap("Recovery failed: " + err.Error())
+	}
+	m.recoveries = m.recoveries[1:]
+	m.recoveryDiff = nil
+	m.recoveryScroll = 0
+
+	path := notePathForSwap(swap)
+	var cmd tea.Cmd
+	m, cmd = openInbuiltEditor(path, m)
+	// Keep the note's saved text one undo step away
+	m.editorHistory.record(snapshotEditor(m.editorContent), editOther, true, time.Now())
+	m.editorContent.SetValue(string(data))
+	m.editorRev++
+	m = m.followCursor()
+	m.editorStatus = "Recovered from swap file"
+	return m, cmd
+}
+
+// NOTE: skipSwap moves on to the next recoverable swap file, if any.
+func (m model) skipSwap(status string) (tea.Model, tea.Cmd) {
+	m.recoveries = m.recoveries[1:]
+	m.recoveryDiff = nil
+	m.recoveryScroll = 0
+	if status != "" {
+		return m, m.list.NewStatusMessage(status)
+	}
+	return m, nil
+}
+
+// NOTE: toggleRecoveryDiff shows or hides the diff between the first pending swap file and its note.
+// The diff is computed here, once, so recoveryView only has to slice it.
+func (m model) toggleRecoveryDiff() model {
+	m.recoveryScroll = 0
+	if m.recoveryDiff != nil {
+		m.recoveryDiff = nil
+		return m
+	}
+	swap := m.recoveries[0]
+	note, _ := os.ReadFile(notePathForSwap(swap))
+	swapped, _ := os.ReadFile(swap)
+	m.recoveryDiff = lineDiff(strings.Split(string(note), "\n"), strings.Split(string(swapped), "\n"))
+	return m
+}
+
+// NOTE: recoveryView asks what to do with the first pending swap file, optionally showing its diff against the note.
+func (m model) recoveryView() string {
+	swap := m.recoveries[0]
+	rel, _ := filepath.Rel(vaultDir, notePathForSwap(swap))
+
+	var b strings.Builder
+	b.WriteString(lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).MarginLeft(2).Render("Unsaved changes found for " + rel))
+	b.WriteString(lipgloss.NewStyle().Foreground(m.theme.Secondary).Render("  (r)ecover  (d)iff  (x) discard  (esc) skip"))
+	b.WriteString("\n\n")
+
+	diff := m.recoveryDiff
+	if diff == nil {
+		return b.String()
+	}
+
+	added := lipgloss.NewStyle().Foreground(m.theme.Primary)
+	removed := lipgloss.NewStyle().Foreground(m.theme.Accent)
+	same := lipgloss.NewStyle().Foreground(m.theme.Muted)
+
+	height := max(1, m.height-6)
+	start := min(m.recoveryScroll, max(0, len(diff)-height))
+	for _, l := range diff[start:min(len(diff), start+height)] {
+		line := string(l.op) + " " + l.text
+		switch l.op {
+		case '+':
+			b.WriteString("  " + added.Render(line) + "\n")
+		case '-':
+			b.WriteString("  " + removed.Render(line) + "\n")
+		default:
+			b.WriteString("  " + same.Render(line) + "\n")
+		}
+	}
+	return b.String()
+}
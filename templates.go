@@ -0,0 +1,208 @@
+// NOTE: Template processing for new notes. Files in .templates/ are run through text/template
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// noteLink points at another journal note, e.g. the previous or next period.
+// It renders as a markdown link relative to the note being written when used directly in a template.
+type noteLink struct {
+	Name string // filename without extension, e.g. 2026-W07
+	Path string // path relative to the vault, e.g. weekly/2026-W07.md
+	from string // directory of the note the link is written into, relative to the vault
+}
+
+func (l noteLink) String() string {
+	rel, err := filepath.Rel(filepath.Join(vaultDir, l.from), filepath.Join(vaultDir, l.Path))
+	if err != nil {
+		rel = l.Path
+	}
+	return fmt.Sprintf("[%s](%s)", l.Name, filepath.ToSlash(rel))
+}
+
+// templateData is the value templates are executed against.
+type templateData struct {
+	Date    time.Time
+	Title   string
+	Mode    string
+	Weekday string
+	Year    int
+	Week    int
+	Start   time.Time
+	End     time.Time
+	Prev    noteLink
+	Next    noteLink
+}
+
+// newTemplateData builds the template context for a note in dir, relative to the vault, which links are relative to.
+func newTemplateData(mode yapMode, date time.Time, title, dir string) templateData {
+	if mode == yapAll {
+		mode = yapDaily
+	}
+	start, end := mode.periodBounds(date)
+	year, week := date.ISOWeek()
+	prev, next := mode.adjacentDates(date)
+	return templateData{
+		Date:    date,
+		Title:   title,
+		Mode:    mode.String(),
+		Weekday: date.Weekday().String(),
+		Year:    year,
+		Week:    week,
+		Start:   start,
+		End:     end,
+		Prev:    mode.link(prev, dir),
+		Next:    mode.link(next, dir),
+	}
+}
+
+// templateFuncs returns the helpers available to templates. prompt looks up
+// a value the user entered during creation; the remaining helpers are pure.
+func templateFuncs(prompt func(name string, def ...string) string) template.FuncMap {
+	return template.FuncMap{
+		"date": func(layout string, t time.Time) string {
+			return formatDate(t, layout)
+		},
+		"now": time.Now,
+		"addDays": func(n int, t time.Time) time.Time {
+			return t.AddDate(0, 0, n)
+		},
+		"prompt": prompt,
+		"upper":  strings.ToUpper,
+		"lower":  strings.ToLower,
+	}
+}
+
+// templateFields returns the prompt names a template asks for, in order.
+func templateFields(src string, data any) ([]string, error) {
+	var fields []string
+	seen := map[string]bool{}
+	prompt := func(name string, _ ...string) string {
+		if !seen[name] {
+			seen[name] = true
+			fields = append(fields, name)
+		}
+		return ""
+	}
+	tpl, err := template.New("note").Funcs(templateFuncs(prompt)).Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := tpl.Execute(&bytes.Buffer{}, data); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// renderTemplate executes a template with the given data and prompt answers.
+func renderTemplate(src string, data any, values map[string]string) ([]byte, error) {
+	prompt := func(name string, def ...string) string {
+		if v := values[name]; v != "" {
+			return v
+		}
+		if len(def) > 0 {
+			return def[0]
+		}
+		return ""
+	}
+	tpl, err := template.New("note").Funcs(templateFuncs(prompt)).Option("missingkey=error").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readTemplate loads a template from the vault's .templates directory.
+func readTemplate(name string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(vaultDir, ".templates", name))
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// listTemplates returns the template filenames in .templates, preceded by ""
+// which stands for "no template".
+func listTemplates() []string {
+	names := []string{""}
+	entries, err := os.ReadDir(filepath.Join(vaultDir, ".templates"))
+	if err != nil {
+		return names
+	}
+	for _, e := range entries {
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// NOTE: formatDate accepts either a Go reference layout (2006-01-02) or strftime directives (%Y-%m-%d)
+func formatDate(t time.Time, layout string) string {
+	if !strings.Contains(layout, "%") {
+		return t.Format(layout)
+	}
+	var b strings.Builder
+	for i := 0; i < len(layout); i++ {
+		if layout[i] != '%' || i+1 == len(layout) {
+			b.WriteByte(layout[i])
+			continue
+		}
+		i++
+		switch layout[i] {
+		case 'Y':
+			b.WriteString(t.Format("2006"))
+		case 'y':
+			b.WriteString(t.Format("06"))
+		case 'm':
+			b.WriteString(t.Format("01"))
+		case 'd':
+			b.WriteString(t.Format("02"))
+		case 'e':
+			b.WriteString(t.Format("_2"))
+		case 'H':
+			b.WriteString(t.Format("15"))
+		case 'M':
+			b.WriteString(t.Format("04"))
+		case 'S':
+			b.WriteString(t.Format("05"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'b':
+			b.WriteString(t.Format("Jan"))
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'V':
+			_, week := t.ISOWeek()
+			fmt.Fprintf(&b, "%02d", week)
+		case 'G':
+			year, _ := t.ISOWeek()
+			fmt.Fprintf(&b, "%d", year)
+		case 'u':
+			fmt.Fprintf(&b, "%d", (int(t.Weekday())+6)%7+1)
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(layout[i])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,73 @@
+// NOTE: tmux support. Graphics escapes are wrapped in tmux's passthrough DCS and placed relative to the pane
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// inTmux reports whether YapPad runs inside a tmux pane.
+var inTmux = os.Getenv("TMUX") != ""
+
+// tmuxDisplay expands a tmux format string for the current pane.
+func tmuxDisplay(format string) ([]string, error) {
+	out, err := exec.Command("tmux", "display-message", "-p", format).Output()
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimSpace(string(out)), "\t"), nil
+}
+
+// NOTE: tmuxClient returns the terminal tmux itself is attached to, and whether the pane may pass escapes through to it.
+// tmux older than 3.3 has no allow-passthrough option and always passes them through.
+func tmuxClient() (term string, passthrough bool) {
+	fields, err := tmuxDisplay("#{client_termname}\t#{allow-passthrough}")
+	if err != nil || len(fields) < 2 {
+		return "", true
+	}
+	return fields[0], fields[1] != "off"
+}
+
+// tmuxPaneOffset returns the pane's position in the tmux window, 0-based, or 0, 0 if it can't be read.
+func tmuxPaneOffset() (top, left int) {
+	fields, err := tmuxDisplay("#{pane_top}\t#{pane_left}")
+	if err != nil || len(fields) < 2 {
+		return 0, 0
+	}
+	top, _ = strconv.Atoi(fields[0])
+	left, _ = strconv.Atoi(fields[1])
+	return top, left
+}
+
+// NOTE: tmuxWrap wraps each escape sequence in a passthrough DCS with its ESCs doubled, so tmux forwards it untouched.
+// Sequences are wrapped one at a time because tmux drops a DCS longer than its input buffer, and a Kitty image
+// is sent as many small chunks.
+func tmuxWrap(seq []byte) []byte {
+	var buf bytes.Buffer
+	for len(seq) > 0 {
+		end := len(seq)
+		if i := bytes.Index(seq, []byte("\x1b\\")); i >= 0 {
+			end = i + 2
+		}
+		buf.WriteString("\x1bPtmux;")
+		buf.Write(bytes.ReplaceAll(seq[:end], []byte("\x1b"), []byte("\x1b\x1b")))
+		buf.WriteString("\x1b\\")
+		seq = seq[end:]
+	}
+	return buf.Bytes()
+}
+
+// writeGraphics writes graphics escapes to the terminal, through tmux when needed.
+func writeGraphics(seq []byte) {
+	if len(seq) == 0 {
+		return
+	}
+	if inTmux {
+		seq = tmuxWrap(seq)
+	}
+	os.Stdout.Write(seq)
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/list"
@@ -10,7 +13,12 @@ import (
 // NOTE: Inbuilt editor using textarea component
 
 type (
-	editorSavedMsg  struct{}
+	editorSavedMsg struct {
+		content string
+		err     error
+		close   bool
+		auto    bool
+	}
 	editorClosedMsg struct{}
 )
 
@@ -21,13 +29,22 @@ type fileEditedMsg struct {
 }
 
 type fileLoadedMsg struct {
-	content string
+	content  string
+	headings []heading
+	language string
+	// tree is the parsed document of a JSON or YAML preview. The model draws and folds it itself, so content is empty.
+	tree []*treeNode
 }
 
-type imageRenderedMsg struct{}
+type imageRenderedMsg struct {
+	err error
+}
 
 type clearViewportMsg struct{}
 
+// statusMsg shows a message in the list's status line, e.g. a problem found while starting up.
+type statusMsg string
+
 // List item
 
 type item struct {
@@ -120,19 +137,166 @@ func (y yapMode) subdir() string {
 
 // defaultNoteName returns the default journal filename for the current time.
 func (y yapMode) defaultNoteName() string {
-	now := time.Now()
+	return y.noteName(time.Now())
+}
+
+// noteName returns the journal filename for the period containing t.
+func (y yapMode) noteName(t time.Time) string {
 	switch y {
 	case yapDaily, yapAll:
-		return now.Format("2006-01-02") + ".md"
+		return t.Format("2006-01-02") + ".md"
 	case yapWeekly:
-		year, week := now.ISOWeek()
+		year, week := t.ISOWeek()
 		return fmt.Sprintf("%d-W%02d.md", year, week)
 	case yapMonthly:
-		return now.Format("2006-01") + ".md"
+		return t.Format("2006-01") + ".md"
 	case yapYearly:
-		return now.Format("2006") + ".md"
+		return t.Format("2006") + ".md"
 	default:
-		return now.Format("2006-01-02") + ".md"
+		return t.Format("2006-01-02") + ".md"
+	}
+}
+
+// noteDate parses the date encoded in a journal filename such as 2026-02-18.md,
+// 2026-W07.md, 2026-02.md or 2026.md. Weekly names resolve to the Monday of
+// the week, monthly and yearly names to the first day of the period.
+func (y yapMode) noteDate(name string) (time.Time, bool) {
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	switch y {
+	case yapDaily, yapAll:
+		t, err := time.ParseInLocation("2006-01-02", base, time.Local)
+		return t, err == nil
+	case yapWeekly:
+		var year, week int
+		if n, err := fmt.Sscanf(base, "%d-W%d", &year, &week); n != 2 || err != nil || week < 1 || week > 53 {
+			return time.Time{}, false
+		}
+		// January 4th is always in ISO week 1
+		start, _ := yapWeekly.periodBounds(time.Date(year, time.January, 4, 0, 0, 0, 0, time.Local))
+		t := start.AddDate(0, 0, (week-1)*7)
+		if y, w := t.ISOWeek(); y != year || w != week {
+			return time.Time{}, false
+		}
+		return t, true
+	case yapMonthly:
+		t, err := time.ParseInLocation("2006-01", base, time.Local)
+		return t, err == nil
+	case yapYearly:
+		t, err := time.ParseInLocation("2006", base, time.Local)
+		return t, err == nil
+	default:
+		return time.Time{}, false
+	}
+}
+
+/*
+	NOTE:
+
+parseTargetDate reads the "@date" syntax accepted by the create prompt.
+Supported forms are @today, @yesterday, @tomorrow, a weekday (@monday,
+the most recent one), a period offset (@-1 is the previous day, week,
+month or year depending on the mode), @2026-02-18, @02-18 (this year) and
+the mode's own filename format, e.g. @2026-W07 in weekly mode.
+*/
+func (y yapMode) parseTargetDate(s string, now time.Time) (time.Time, bool) {
+	if !strings.HasPrefix(s, "@") {
+		return time.Time{}, false
+	}
+	s = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(s, "@")))
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch s {
+	case "today", "now":
+		return today, true
+	case "yesterday":
+		return today.AddDate(0, 0, -1), true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		name := strings.ToLower(d.String())
+		if s == name || s == name[:3] {
+			return today.AddDate(0, 0, -((int(today.Weekday()) - int(d) + 7) % 7)), true
+		}
+	}
+
+	if strings.HasPrefix(s, "-") || strings.HasPrefix(s, "+") {
+		// Atoi rejects trailing text, so "@+2days" isn't read as "@+2"
+		if n, err := strconv.Atoi(s); err == nil {
+			switch y {
+			case yapWeekly:
+				return today.AddDate(0, 0, 7*n), true
+			case yapMonthly:
+				start, _ := y.periodBounds(today)
+				return start.AddDate(0, n, 0), true
+			case yapYearly:
+				start, _ := y.periodBounds(today)
+				return start.AddDate(n, 0, 0), true
+			default:
+				return today.AddDate(0, 0, n), true
+			}
+		}
+	}
+
+	if t, err := time.ParseInLocation("2006-01-02", s, now.Location()); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("01-02", s, now.Location()); err == nil && y != yapMonthly {
+		// Feb 29 parses (year 0 is a leap year) but doesn't exist in most years; don't let it become Mar 1
+		d := time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+		if d.Month() != t.Month() || d.Day() != t.Day() {
+			return time.Time{}, false
+		}
+		return d, true
+	}
+	if t, ok := y.noteDate(strings.ToUpper(s)); ok {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// periodBounds returns the first and last day of the period containing t.
+// Weeks follow ISO 8601 and start on Monday.
+func (y yapMode) periodBounds(t time.Time) (time.Time, time.Time) {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch y {
+	case yapWeekly:
+		start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 6)
+	case yapMonthly:
+		start := day.AddDate(0, 0, 1-day.Day())
+		return start, start.AddDate(0, 1, -1)
+	case yapYearly:
+		start := time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, day.Location())
+		return start, start.AddDate(1, 0, -1)
+	default:
+		return day, day
+	}
+}
+
+// adjacentDates returns a date inside the previous and the next period.
+func (y yapMode) adjacentDates(t time.Time) (time.Time, time.Time) {
+	start, _ := y.periodBounds(t)
+	switch y {
+	case yapWeekly:
+		return start.AddDate(0, 0, -7), start.AddDate(0, 0, 7)
+	case yapMonthly:
+		return start.AddDate(0, -1, 0), start.AddDate(0, 1, 0)
+	case yapYearly:
+		return start.AddDate(-1, 0, 0), start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 0, -1), start.AddDate(0, 0, 1)
+	}
+}
+
+// link returns a reference to the journal note for the period containing t, for use in a note in from.
+func (y yapMode) link(t time.Time, from string) noteLink {
+	name := y.noteName(t)
+	return noteLink{
+		Name: strings.TrimSuffix(name, ".md"),
+		Path: filepath.Join(y.defaultNoteDir(), name),
+		from: from,
 	}
 }
 
@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
@@ -31,7 +32,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		var clearCmd tea.Cmd
 		if m.showingImage {
 			m.showingImage = false
-			clearCmd = clearKittyGraphics()
+			clearCmd = clearGraphics()
 		}
 
 		//  NOTE: Hard coded for now I'll need a better approach
@@ -57,6 +58,13 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		m.list.SetSize(listWidth, msg.Height-5)
 
+		if m.editorMode {
+			m = m.resizeEditor()
+			if m.editorPreview {
+				return m, tea.Batch(clearCmd, m.renderEditorPreview(m.editorRev))
+			}
+		}
+
 		//  HACK: Not the best way, will fix later
 		if !m.ready {
 			m.viewport = viewport.New(viewportWidth, msg.Height-10)
@@ -104,10 +112,86 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		wrapped := wordwrap.String(msg.content, m.viewport.Width)
 		m.viewport.SetContent(wrapped)
 		m.viewport.GotoTop()
+		m.outline = locateHeadings(msg.headings, wrapped)
+		m.outlineOpen = false
+		m.previewLanguage = msg.language
+		m.previewTree = msg.tree
+		m.treeRows = nil
+		m.treeFocus = false
+		m.treeCursor = 0
+		if msg.tree != nil {
+			m = m.redrawTree()
+		}
 
 	case editorSavedMsg:
 		m.list.SetItems(listFiles(m.sortMode, m.yapMode))
-		return m, m.list.NewStatusMessage("Saved!")
+		if msg.err != nil {
+			m.editorStatus = "Save failed: " + msg.err.Error()
+			return m, nil
+		}
+		m.editorSaved = msg.content
+		m.editorStatus = "Saved!"
+		if msg.auto {
+			m.editorStatus = "Autosaved"
+		}
+		if rel := m.editorRelPath(); cfg.WordGoals[goalMode(rel)] > 0 {
+			if err := m.wordStats.recordWordCount(rel, len(strings.Fields(msg.content)), time.Now()); err != nil {
+				m.editorStatus = "Saved, but word count not recorded: " + err.Error()
+			}
+		}
+		if msg.close {
+			return m.closeEditor()
+		}
+		if !m.editorDirty() {
+			removeSwap(m.editorFile)
+		}
+		return m, nil
+
+	case swapTickMsg:
+		if m.editorMode && msg.rev == m.editorRev && m.editorDirty() {
+			writeSwap(m.editorFile, m.editorContent.Value())
+		}
+		return m, nil
+
+	case editorPreviewTickMsg:
+		if m.editorMode && m.editorPreview && msg.rev == m.editorRev {
+			return m, m.renderEditorPreview(msg.rev)
+		}
+		return m, nil
+
+	case editorPreviewMsg:
+		if m.editorMode && m.editorPreview && msg.rev == m.editorRev {
+			m.editorPreviewPane.SetContent(msg.content)
+			m = m.syncEditorPreview()
+		}
+		return m, nil
+
+	case autosaveTickMsg:
+		if m.editorMode && msg.rev == m.editorRev && m.editorDirty() {
+			return m, saveEditorContent(m.editorFile, m.editorContent.Value(), false, true)
+		}
+		return m, nil
+
+	case rollupDoneMsg:
+		if msg.err != nil {
+			return m, m.list.NewStatusMessage("Rollup failed: " + msg.err.Error())
+		}
+		m.list.SetItems(listFiles(m.sortMode, m.yapMode))
+		rel, _ := filepath.Rel(vaultDir, msg.path)
+		if m.yapMode != yapAll {
+			m.selectedFile = filepath.Base(msg.path)
+		} else {
+			m.selectedFile = rel
+		}
+		if m.editor == "inbuilt" {
+			var editorCmd tea.Cmd
+			m, editorCmd = openInbuiltEditor(msg.path, m)
+			return m, editorCmd
+		}
+		return m, openInEditor(msg.path, m.editor)
+
+	case statusMsg:
+		return m, m.list.NewStatusMessage(string(msg))
 
 	case clearViewportMsg:
 		// Blank the viewport so old text doesn't bleed under image overlay
@@ -116,6 +200,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case imageRenderedMsg:
 		// Image was drawn directly to stdout as overlay.
 		m.loadingFile = false
+		m.outline = nil
+		m.outlineOpen = false
+		m.previewLanguage = ""
+		m.previewTree = nil
+		m.treeRows = nil
+		m.treeFocus = false
+		if msg.err != nil {
+			m.showingImage = false
+			m.viewport.SetContent("Cannot preview image: " + msg.err.Error())
+			return m, nil
+		}
 		m.showingImage = true
 
 	case tea.MouseMsg:
@@ -175,22 +270,74 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		// NOTE: Inbuilt textarea
 		if m.editorMode {
+			return m.updateEditor(msg)
+		}
+
+		// SWAP FILE RECOVERY
+		if len(m.recoveries) > 0 {
 			switch msg.String() {
-			case "ctrl+s":
-				return m, saveEditorContent(m.editorFile, m.editorContent.Value())
-			case "ctrl+q":
-				m.editorMode = false
-				m.editorContent.Blur()
-				m.list.SetItems(listFiles(m.sortMode, m.yapMode))
-				if m.showPreview {
+			case "r", "R":
+				return m.recoverSwap(m.recoveries[0])
+			case "d", "D":
+				m = m.toggleRecoveryDiff()
+			case "j", "down":
+				m.recoveryScroll++
+			case "k", "up":
+				m.recoveryScroll = max(0, m.recoveryScroll-1)
+			case "x", "X":
+				os.Remove(m.recoveries[0])
+				return m.skipSwap("Discarded swap file")
+			case "esc":
+				return m.skipSwap("")
+			case "ctrl+c":
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+
+		// ON THIS DAY
+		if m.onThisDay {
+			switch msg.String() {
+			case "up", "k":
+				if m.memoryIndex > 0 {
+					m.memoryIndex--
+				}
+			case "down", "j":
+				if m.memoryIndex < len(m.memories)-1 {
+					m.memoryIndex++
+				}
+			case "enter":
+				if len(m.memories) == 0 {
+					return m, nil
+				}
+				path := m.memories[m.memoryIndex].path
+				m.onThisDay = false
+				if m.editor == "inbuilt" {
+					var editorCmd tea.Cmd
+					m, editorCmd = openInbuiltEditor(path, m)
+					return m, editorCmd
+				}
+				return m, openInEditor(path, m.editor)
+			case "esc", "q", "ctrl+o":
+				m.onThisDay = false
+				if m.showPreview && m.selectedFile != "" {
 					m.loadingFile = true
 					return m, tea.Batch(m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
 				}
-				return m, nil
+			case "ctrl+c":
+				return m, tea.Quit
 			}
-			var editorCmd tea.Cmd
-			m.editorContent, editorCmd = m.editorContent.Update(msg)
-			return m, editorCmd
+			return m, nil
+		}
+
+		// OUTLINE
+		if m.outlineOpen {
+			return m.updateOutline(msg)
+		}
+
+		// TREE CURSOR
+		if m.treeFocus {
+			return m.updateTree(msg)
 		}
 
 		// DELETE CONFIRMATION MODE
@@ -285,14 +432,36 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					return m, nil
 				}
 
-				// create the file
+				if m.inputStep == 3 {
+					m.promptValues[m.promptFields[m.promptIndex]] = m.promptInput.Value()
+					m.promptIndex++
+					if m.promptIndex < len(m.promptFields) {
+						m.promptInput.SetValue("")
+						m.promptInput.Placeholder = m.promptFields[m.promptIndex]
+						return m, nil
+					}
+					return m.createNote()
+				}
+
+				if m.inputStep == 2 {
+					return m.applyTemplate(m.templates[m.templateIndex])
+				}
+
+				// resolve the target path and template
 				name := m.input.Value()
-				desc := m.descInput.Value()
 
 				var path string
+				m.noteDate = time.Now()
+				if date, ok := m.yapMode.parseTargetDate(name, time.Now()); ok {
+					// Backdated (or future) journal entry, named after its period
+					m.noteDate = date
+					name = ""
+				} else if strings.HasPrefix(name, "@") {
+					return m.cancelCreate("Unrecognised date: " + strings.TrimPrefix(name, "@"))
+				}
 				if name == "" {
 					subdir := m.yapMode.defaultNoteDir()
-					defaultName := m.yapMode.defaultNoteName()
+					defaultName := m.yapMode.noteName(m.noteDate)
 					path = filepath.Join(vaultDir, subdir, defaultName)
 				} else {
 					if filepath.Ext(name) == "" {
@@ -301,50 +470,44 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					path = filepath.Join(vaultDir, name)
 				}
 
-				if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				m.pendingNote = path
+				m.templateSrc = ""
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					return m.createNote()
 				}
 
-				if _, err := os.Stat(path); os.IsNotExist(err) {
-					var content []byte
-					if m.input.Value() == "" {
-						tplPath := filepath.Join(vaultDir, ".templates", m.yapMode.defaultNoteDir()+".md")
-						if tplData, err := os.ReadFile(tplPath); err == nil {
-							content = tplData
+				// Existing notes are opened as-is, new ones pick a template first
+				m.templates = listTemplates()
+				if len(m.templates) == 1 {
+					return m.createNote()
+				}
+				m.templateIndex = 0
+				if name == "" {
+					for i, t := range m.templates {
+						if t == m.yapMode.defaultNoteDir()+".md" {
+							m.templateIndex = i
 						}
 					}
-					os.WriteFile(path, content, 0o644)
 				}
+				m.inputStep = 2
+				m.descInput.Blur()
+				return m, nil
 
-				writeMetaDesc(path, desc)
-
-				m.inputMode = false
-				m.inputStep = 0
-				m.input.SetValue("")
-				m.descInput.SetValue("")
-				m.input.Focus()
+			case "esc":
+				m.renameMode = false
+				return m.cancelCreate("")
 
-				rel, _ := filepath.Rel(vaultDir, path)
-				if m.yapMode != yapAll {
-					m.selectedFile = filepath.Base(path)
-				} else {
-					m.selectedFile = rel
-				}
-				if m.editor == "inbuilt" {
-					var editorCmd tea.Cmd
-					m, editorCmd = openInbuiltEditor(path, m)
-					return m, editorCmd
+			case "up", "ctrl+k":
+				if m.inputStep == 2 {
+					m.templateIndex = (m.templateIndex - 1 + len(m.templates)) % len(m.templates)
+					return m, nil
 				}
-				return m, openInEditor(path, m.editor)
 
-			case "esc":
-				m.inputMode = false
-				m.renameMode = false
-				m.inputStep = 0
-				m.input.SetValue("")
-				m.descInput.SetValue("")
-				m.input.Focus()
-				m.list.SetItems(listFiles(m.sortMode, m.yapMode))
-				return m, nil
+			case "down", "ctrl+j":
+				if m.inputStep == 2 {
+					m.templateIndex = (m.templateIndex + 1) % len(m.templates)
+					return m, nil
+				}
 
 			case "tab":
 				if m.inputStep == 0 {
@@ -358,17 +521,25 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					case yapYearly:
 						m.yapMode = yapDaily
 					}
-					m.input.Placeholder = fmt.Sprintf("%s/%s (default)", m.yapMode.defaultNoteDir(), m.yapMode.defaultNoteName())
+					m.input.Placeholder = fmt.Sprintf("%s/%s (default, or @date)", m.yapMode.defaultNoteDir(), m.yapMode.defaultNoteName())
 					m.list.SetItems(listFiles(m.sortMode, m.yapMode))
 				}
 				return m, nil
 			}
 
-			if m.inputStep == 0 {
+			if m.inputStep == 2 {
+				return m, nil
+			} else if m.inputStep == 3 {
+				m.promptInput, cmd = m.promptInput.Update(msg)
+			} else if m.inputStep == 0 {
 				m.input, cmd = m.input.Update(msg)
 
 				// Live-filter list based on typed input
 				val := m.input.Value()
+				if date, ok := m.yapMode.parseTargetDate(val, time.Now()); ok {
+					m.list.SetItems(listFiles(m.sortMode, m.yapMode))
+					return m, tea.Batch(cmd, m.list.NewStatusMessage("Creates "+filepath.Join(m.yapMode.defaultNoteDir(), m.yapMode.noteName(date))))
+				}
 				if val != "" {
 					allItems := listFiles(m.sortMode, yapAll)
 					var filtered []list.Item
@@ -394,7 +565,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 		case key.Matches(msg, m.keys.New):
 			m.inputMode = true
-			m.input.Placeholder = fmt.Sprintf("%s/%s (default)", m.yapMode.defaultNoteDir(), m.yapMode.defaultNoteName())
+			m.input.Placeholder = fmt.Sprintf("%s/%s (default, or @date)", m.yapMode.defaultNoteDir(), m.yapMode.defaultNoteName())
 			m.input.Focus()
 			return m, nil
 
@@ -430,6 +601,77 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 			return m, nil
 
+		case key.Matches(msg, m.keys.Rollup):
+			if _, ok := m.yapMode.rollupSource(); !ok {
+				return m, m.list.NewStatusMessage("Rollups are available in weekly, monthly and yearly mode")
+			}
+			// Roll up the selected period, or the current one if nothing parsable is selected
+			date := time.Now()
+			if it, ok := m.list.SelectedItem().(item); ok {
+				if d, ok := m.yapMode.noteDate(it.title); ok {
+					date = d
+				}
+			}
+			return m, buildRollup(m.yapMode, date)
+
+		case key.Matches(msg, m.keys.OnThisDay) && m.yapMode == yapDaily:
+			m.memories = collectMemories(time.Now())
+			m.memoryIndex = 0
+			m.onThisDay = true
+			return m, clearGraphics()
+
+		case key.Matches(msg, m.keys.Outline):
+			return m.openOutline()
+
+		case key.Matches(msg, m.keys.NextHeading) && m.list.FilterState() != list.Filtering:
+			return m.jumpHeading(true)
+
+		case key.Matches(msg, m.keys.PrevHeading) && m.list.FilterState() != list.Filtering:
+			return m.jumpHeading(false)
+
+		case key.Matches(msg, m.keys.LineNumbers):
+			m.lineNumbers = !m.lineNumbers
+			status := "Line numbers off"
+			if m.lineNumbers {
+				status = "Line numbers on"
+			}
+			cmds := []tea.Cmd{m.list.NewStatusMessage(status)}
+			if m.showPreview && m.selectedFile != "" {
+				m.loadingFile = true
+				cmds = append(cmds, m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
+			}
+			return m, tea.Batch(cmds...)
+
+		case key.Matches(msg, m.keys.ExpandTree) && m.previewTree != nil && m.list.FilterState() != list.Filtering:
+			return m.foldTree(1)
+
+		case key.Matches(msg, m.keys.CollapseTree) && m.previewTree != nil && m.list.FilterState() != list.Filtering:
+			return m.foldTree(-1)
+
+		case key.Matches(msg, m.keys.FoldTree):
+			return m.openTree()
+
+		case key.Matches(msg, m.keys.CycleTheme):
+			names := themeNames()
+			next := names[0]
+			for i, name := range names {
+				if name == m.themeName && i+1 < len(names) {
+					next = names[i+1]
+				}
+			}
+			m.themeName = next
+			m.theme = getTheme(next)
+			status := "Theme: " + next
+			if err := setGlamourTheme(next, m.theme); err != nil {
+				status = "Theme: " + next + " (style error: " + err.Error() + ")"
+			}
+			cmds := []tea.Cmd{m.list.NewStatusMessage(status)}
+			if m.showPreview && m.selectedFile != "" {
+				m.loadingFile = true
+				cmds = append(cmds, m.spinner.Tick, m.loadFileOrImage(m.resolveFilePath(m.selectedFile)))
+			}
+			return m, tea.Batch(cmds...)
+
 		case key.Matches(msg, m.keys.ToggleHelpMenu):
 			m.list.SetShowHelp(!m.list.ShowHelp())
 			return m, nil
@@ -443,7 +685,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			if !m.showPreview {
 				m.showingImage = false
-				return m, tea.Batch(resizeCmd, clearKittyGraphics())
+				return m, tea.Batch(resizeCmd, clearGraphics())
 			}
 
 			if m.selectedFile != "" {
@@ -490,6 +732,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	// Paste results and cursor blinks belong to the textarea while editing
+	if m.editorMode {
+		return m.updateEditorContent(msg)
+	}
+
 	var cmdList tea.Cmd
 	m.list, cmdList = m.list.Update(msg)
 
@@ -83,10 +83,14 @@ func (m model) listItemStyles() list.DefaultItemStyles {
 
 func (m model) previewHeader() string {
 	title := m.previewHeaderStyle().Render(m.selectedFile)
+	language := ""
+	if m.previewLanguage != "" {
+		language = lipgloss.NewStyle().Foreground(m.theme.Muted).Render(" Language: " + m.previewLanguage + " ")
+	}
 	line := lipgloss.NewStyle().Foreground(m.theme.Border).Render(
-		strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title))),
+		strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)-lipgloss.Width(language))),
 	)
-	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
+	return lipgloss.JoinHorizontal(lipgloss.Center, title, line, language)
 }
 
 func (m model) previewFooter() string {
@@ -97,6 +101,68 @@ func (m model) previewFooter() string {
 	return lipgloss.JoinHorizontal(lipgloss.Center, line, info)
 }
 
+// NOTE: templatePicker renders the template choices next to a raw preview of the highlighted one.
+func (m model) templatePicker() string {
+	var rows []string
+	rows = append(rows, m.statusStyle().Render("Template (↑/↓ to choose, enter to create)"), "")
+	for i, name := range m.templates {
+		label := name
+		if label == "" {
+			label = "(none)"
+		}
+		if i == m.templateIndex {
+			rows = append(rows, lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).Render("  > "+label))
+		} else {
+			rows = append(rows, lipgloss.NewStyle().Foreground(m.theme.Text).Render("    "+label))
+		}
+	}
+	picker := lipgloss.NewStyle().Width(max(24, m.width/3)).Render(strings.Join(rows, "\n"))
+
+	preview := "(empty note)"
+	if name := m.templates[m.templateIndex]; name != "" {
+		if src, ok := readTemplate(name); ok {
+			preview = src
+		}
+	}
+	previewWidth := max(20, m.width-lipgloss.Width(picker)-6)
+	previewHeight := max(3, m.height-12)
+	lines := strings.Split(strings.TrimRight(preview, "\n"), "\n")
+	if len(lines) > previewHeight {
+		lines = lines[:previewHeight]
+	}
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(m.theme.Border).
+		Foreground(m.theme.SubText).
+		Padding(0, 1).
+		Width(previewWidth).
+		Render(strings.Join(lines, "\n"))
+
+	return lipgloss.JoinHorizontal(lipgloss.Top, picker, box)
+}
+
+// NOTE: editorHeader shows the file being edited and either the key hints, the close prompt or the last save result.
+func (m model) editorHeader(title string) string {
+	name := m.statusStyle().Render(m.editorRelPath())
+
+	var status string
+	switch {
+	case m.editorConfirm:
+		status = lipgloss.NewStyle().Foreground(m.theme.Accent).Bold(true).MarginLeft(2).Render("Unsaved changes!") +
+			lipgloss.NewStyle().Foreground(m.theme.Secondary).Render(" (s)ave  (d)iscard  (c)ancel")
+	case m.spellMenu.active:
+		status = m.spellMenuView()
+	case m.editorStatus != "":
+		status = lipgloss.NewStyle().Foreground(m.theme.Accent).MarginLeft(2).Render(m.editorStatus)
+	default:
+		status = m.statusStyle().Render("ctrl+s: save  ctrl+q: close  ctrl+f: find  ctrl+p: preview")
+	}
+	if vimEnabled {
+		status = m.vimStatus() + status
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Center, title, name, status)
+}
+
 func (m model) View() string {
 	delegate := list.NewDefaultDelegate()
 	delegate.Styles = m.listItemStyles()
@@ -140,12 +206,35 @@ func (m model) View() string {
 		)
 	}
 
+	if len(m.recoveries) > 0 && !m.editorMode {
+		return fmt.Sprintf(
+			"\n%s\n\n%s",
+			header,
+			m.recoveryView(),
+		)
+	}
+
+	if m.onThisDay {
+		return fmt.Sprintf(
+			"\n%s\n\n%s",
+			header,
+			m.onThisDayView(),
+		)
+	}
+
 	if m.editorMode {
-		editorStatus := m.statusStyle().Render("ctrl+s: save  ctrl+q: close")
+		body := m.renderEditor()
+		if m.editorPreview {
+			body = m.editorPreviewView(body)
+		}
+		body += "\n" + m.editorStatusLine()
+		if m.search.active {
+			body += "\n" + m.searchBar()
+		}
 		return fmt.Sprintf(
 			"\n%s\n\n%s",
-			lipgloss.JoinHorizontal(lipgloss.Center, title, editorStatus),
-			m.editorContent.View(),
+			m.editorHeader(title),
+			body,
 		)
 	}
 
@@ -158,6 +247,26 @@ func (m model) View() string {
 				m.list.View(),
 			)
 		}
+		if m.inputStep == 2 {
+			return fmt.Sprintf(
+				"\n%s\n\n File Name %s\n Description %s\n\n%s",
+				header,
+				m.input.View(),
+				m.descInput.View(),
+				m.templatePicker(),
+			)
+		}
+		if m.inputStep == 3 {
+			return fmt.Sprintf(
+				"\n%s\n\n File Name %s\n Description %s\n %s %s\n\n%s",
+				header,
+				m.input.View(),
+				m.descInput.View(),
+				m.promptFields[m.promptIndex],
+				m.promptInput.View(),
+				m.list.View(),
+			)
+		}
 		return fmt.Sprintf(
 			"\n%s\n\n File Name %s\n Description %s\n\n%s",
 			header,
@@ -169,7 +278,11 @@ func (m model) View() string {
 
 	if m.showPreview {
 		listWidth := m.width / 2
-		spacer := strings.Repeat(" ", max(0, listWidth-lipgloss.Width(m.list.View())))
+		left := m.list.View()
+		if m.outlineOpen {
+			left = m.outlineView(listWidth, lipgloss.Height(left))
+		}
+		spacer := strings.Repeat(" ", max(0, listWidth-lipgloss.Width(left)))
 
 		var previewView string
 		if m.loadingFile {
@@ -182,7 +295,7 @@ func (m model) View() string {
 		return fmt.Sprintf(
 			"\n%s\n\n%s",
 			header,
-			lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), spacer, previewView),
+			lipgloss.JoinHorizontal(lipgloss.Top, left, spacer, previewView),
 		)
 	}
 
@@ -0,0 +1,556 @@
+// NOTE: Optional vim-style modal layer for the inbuilt editor (--vim)
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+type vimMode int
+
+const (
+	vimNormal vimMode = iota
+	vimInsert
+	vimVisual
+	vimVisualLine
+)
+
+func (v vimMode) String() string {
+	switch v {
+	case vimInsert:
+		return "INSERT"
+	case vimVisual:
+		return "VISUAL"
+	case vimVisualLine:
+		return "VISUAL LINE"
+	default:
+		return "NORMAL"
+	}
+}
+
+// vimState is the modal layer's state. The textarea still owns the text and cursor.
+type vimState struct {
+	mode      vimMode
+	count     string
+	operator  string // pending d, c or y
+	opCount   int    // count typed before the pending operator, 0 for none
+	pendingG  bool
+	anchor    int // visual selection start, as a rune offset
+	register  string
+	linewise  bool
+	command   string
+	commandOn bool
+}
+
+// vimBuffer is the editor text flattened to runes so motions can cross lines.
+type vimBuffer struct {
+	runes []rune
+}
+
+func (b vimBuffer) offset(row, col int) int {
+	r := 0
+	for i, c := range b.runes {
+		if r == row {
+			return min(i+col, b.lineEnd(i))
+		}
+		if c == '\n' {
+			r++
+		}
+	}
+	return len(b.runes)
+}
+
+func (b vimBuffer) position(off int) (int, int) {
+	row, start := 0, 0
+	for i := 0; i < off && i < len(b.runes); i++ {
+		if b.runes[i] == '\n' {
+			row++
+			start = i + 1
+		}
+	}
+	return row, off - start
+}
+
+func (b vimBuffer) lineStart(off int) int {
+	for off > 0 && b.runes[off-1] != '\n' {
+		off--
+	}
+	return off
+}
+
+func (b vimBuffer) lineEnd(off int) int {
+	for off < len(b.runes) && b.runes[off] != '\n' {
+		off++
+	}
+	return off
+}
+
+// lastChar is the offset of the last character on the line, where the normal mode cursor stops.
+func (b vimBuffer) lastChar(off int) int {
+	return max(b.lineStart(off), b.lineEnd(off)-1)
+}
+
+// charClass groups runes for word motions: 0 whitespace, 1 word characters, 2 punctuation.
+func charClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return 1
+	default:
+		return 2
+	}
+}
+
+func (b vimBuffer) wordForward(off int) int {
+	n := len(b.runes)
+	if off >= n {
+		return n
+	}
+	if c := charClass(b.runes[off]); c != 0 {
+		for off < n && charClass(b.runes[off]) == c {
+			off++
+		}
+	}
+	for off < n && charClass(b.runes[off]) == 0 {
+		off++
+	}
+	return off
+}
+
+func (b vimBuffer) wordEnd(off int) int {
+	n := len(b.runes)
+	off++
+	for off < n && charClass(b.runes[off]) == 0 {
+		off++
+	}
+	if off >= n {
+		return max(0, n-1)
+	}
+	c := charClass(b.runes[off])
+	for off+1 < n && charClass(b.runes[off+1]) == c {
+		off++
+	}
+	return off
+}
+
+func (b vimBuffer) wordBackward(off int) int {
+	off--
+	for off > 0 && charClass(b.runes[off]) == 0 {
+		off--
+	}
+	if off <= 0 {
+		return 0
+	}
+	c := charClass(b.runes[off])
+	for off > 0 && charClass(b.runes[off-1]) == c {
+		off--
+	}
+	return off
+}
+
+// vimMotion is the result of a motion: where the cursor lands and how an operator should treat the range.
+type vimMotion struct {
+	to        int
+	linewise  bool
+	inclusive bool
+}
+
+// motion applies a motion key count times from off. ok is false for keys that aren't motions.
+func (b vimBuffer) motion(key string, off, count int, explicitCount bool) (vimMotion, bool) {
+	row, col := b.position(off)
+	lines := strings.Count(string(b.runes), "\n") + 1
+	switch key {
+	case "h", "left":
+		return vimMotion{to: max(b.lineStart(off), off-count)}, true
+	case "l", "right":
+		return vimMotion{to: min(b.lineEnd(off), off+count)}, true
+	case "j", "down":
+		r := min(lines-1, row+count)
+		return vimMotion{to: b.offset(r, col), linewise: true}, true
+	case "k", "up":
+		r := max(0, row-count)
+		return vimMotion{to: b.offset(r, col), linewise: true}, true
+	case "0", "home":
+		return vimMotion{to: b.lineStart(off)}, true
+	case "^":
+		to := b.lineStart(off)
+		for to < b.lineEnd(off) && unicode.IsSpace(b.runes[to]) {
+			to++
+		}
+		return vimMotion{to: to}, true
+	case "$", "end":
+		return vimMotion{to: b.lastChar(off), inclusive: true}, true
+	case "w":
+		for range count {
+			off = b.wordForward(off)
+		}
+		return vimMotion{to: off}, true
+	case "e":
+		for range count {
+			off = b.wordEnd(off)
+		}
+		return vimMotion{to: off, inclusive: true}, true
+	case "b":
+		for range count {
+			off = b.wordBackward(off)
+		}
+		return vimMotion{to: off}, true
+	case "G":
+		r := lines - 1
+		if explicitCount {
+			r = min(lines-1, count-1)
+		}
+		return vimMotion{to: b.offset(r, 0), linewise: true}, true
+	case "gg":
+		r := 0
+		if explicitCount {
+			r = min(lines-1, count-1)
+		}
+		return vimMotion{to: b.offset(r, 0), linewise: true}, true
+	}
+	return vimMotion{}, false
+}
+
+// NOTE: vimBufferState reads the textarea into a flat buffer plus cursor offset.
+func (m model) vimBufferState() (vimBuffer, int) {
+	b := vimBuffer{runes: []rune(m.editorContent.Value())}
+	row, col := m.editorCursor()
+	return b, b.offset(row, col)
+}
+
+// NOTE: vimMoveTo places the cursor at a buffer offset, keeping it on a character outside insert mode.
+func (m model) vimMoveTo(b vimBuffer, off int) model {
+	off = max(0, min(off, len(b.runes)))
+	if m.vim.mode != vimInsert {
+		off = min(off, b.lastChar(off))
+	}
+	row, col := b.position(off)
+	setEditorCursor(&m.editorContent, row, col)
+	return m.followCursor()
+}
+
+// NOTE: vimReplace swaps runes [start, end) for text as one undo step and leaves the cursor at cursor.
+func (m model) vimReplace(b vimBuffer, start, end int, text string, cursor int) (model, tea.Cmd) {
+	m.editorHistory.record(snapshotEditor(m.editorContent), editOther, true, time.Now())
+	value := string(b.runes[:start]) + text + string(b.runes[end:])
+	m.editorContent.SetValue(value)
+	m.editorRev++
+	m = m.vimMoveTo(vimBuffer{runes: []rune(value)}, cursor)
+	return m, scheduleEditorTicks(m.editorRev)
+}
+
+// lineBounds expands two offsets to the whole lines they touch, without the final newline.
+func (b vimBuffer) lineBounds(a, c int) (int, int) {
+	return b.lineStart(min(a, c)), b.lineEnd(max(a, c))
+}
+
+// NOTE: vimOperate runs d, c or y over [start, end). Linewise ranges come from lineBounds.
+func (m model) vimOperate(op string, b vimBuffer, start, end int, linewise bool) (model, tea.Cmd) {
+	m.vim.register = string(b.runes[start:end])
+	m.vim.linewise = linewise
+	if linewise {
+		m.vim.register += "\n"
+	}
+
+	switch op {
+	case "y":
+		m = m.vimMoveTo(b, start)
+		return m, nil
+	case "c":
+		// A linewise change keeps the (now empty) line to type into
+		m.vim.mode = vimInsert
+		return m.vimReplace(b, start, end, "", start)
+	default:
+		cursor := start
+		if linewise {
+			if end < len(b.runes) {
+				end++
+			} else if start > 0 {
+				// Deleting the last line takes the newline before it and leaves the cursor on the line above
+				start--
+				cursor = b.lineStart(start)
+			}
+		}
+		return m.vimReplace(b, start, end, "", cursor)
+	}
+}
+
+// NOTE: vimPaste puts the register after (p) or before (P) the cursor.
+func (m model) vimPaste(b vimBuffer, off int, before bool, count int) (model, tea.Cmd) {
+	if m.vim.register == "" {
+		return m, nil
+	}
+	text := strings.Repeat(m.vim.register, count)
+	if m.vim.linewise {
+		at := b.lineStart(off)
+		if !before {
+			at = b.lineEnd(off)
+			if at == len(b.runes) {
+				// Pasting below the last line needs a newline in front instead of behind
+				text = "\n" + strings.TrimSuffix(text, "\n")
+				return m.vimReplace(b, at, at, text, at+1)
+			}
+			at++
+		}
+		return m.vimReplace(b, at, at, text, at)
+	}
+	at := off
+	if !before && off < b.lineEnd(off) {
+		at++
+	}
+	return m.vimReplace(b, at, at, text, at+len([]rune(text))-1)
+}
+
+// NOTE: vimCommand runs an ex command from the ":" prompt.
+func (m model) vimCommand(cmd string) (tea.Model, tea.Cmd) {
+	content := m.editorContent.Value()
+	switch strings.TrimSpace(cmd) {
+	case "w":
+		return m, saveEditorContent(m.editorFile, content, false, false)
+	case "wq", "x":
+		return m, saveEditorContent(m.editorFile, content, true, false)
+	case "q":
+		if m.editorDirty() {
+			m.editorStatus = "No write since last change (add ! to override)"
+			return m, nil
+		}
+		return m.closeEditor()
+	case "q!":
+		return m.closeEditor()
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(cmd)); err == nil {
+		b, off := m.vimBufferState()
+		mo, _ := b.motion("G", off, n, true)
+		return m.vimMoveTo(b, mo.to), nil
+	}
+	m.editorStatus = "Not an editor command: " + cmd
+	return m, nil
+}
+
+// NOTE: updateVim handles keys in normal and visual mode. Insert mode goes straight to the textarea.
+func (m model) updateVim(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+
+	if m.vim.commandOn {
+		switch key {
+		case "esc":
+			m.vim.commandOn = false
+		case "enter":
+			m.vim.commandOn = false
+			return m.vimCommand(m.vim.command)
+		case "backspace":
+			if m.vim.command == "" {
+				m.vim.commandOn = false
+			} else {
+				r := []rune(m.vim.command)
+				m.vim.command = string(r[:len(r)-1])
+			}
+		default:
+			if msg.Type == tea.KeyRunes || msg.Type == tea.KeySpace {
+				m.vim.command += string(msg.Runes)
+			}
+		}
+		return m, nil
+	}
+
+	// Counts: a leading 0 is the line-start motion, not a count
+	if len(key) == 1 && key[0] >= '0' && key[0] <= '9' && (key != "0" || m.vim.count != "") {
+		m.vim.count += key
+		return m, nil
+	}
+	count, explicit := 1, false
+	if m.vim.count != "" {
+		count, _ = strconv.Atoi(m.vim.count)
+		explicit = true
+	}
+	// 2d3w deletes six words: the counts before and after the operator multiply
+	if m.vim.operator != "" && m.vim.opCount > 0 {
+		count *= m.vim.opCount
+		explicit = true
+	}
+	if m.vim.pendingG {
+		m.vim.pendingG = false
+		if key == "g" {
+			key = "gg"
+		} else {
+			m.vim.count, m.vim.operator, m.vim.opCount = "", "", 0
+			return m, nil
+		}
+	} else if key == "g" {
+		m.vim.pendingG = true
+		return m, nil
+	}
+
+	b, off := m.vimBufferState()
+	visual := m.vim.mode == vimVisual || m.vim.mode == vimVisualLine
+
+	// Motions move the cursor, extend a visual selection or complete a pending operator
+	if mo, ok := b.motion(key, off, count, explicit); ok {
+		m.vim.count = ""
+		if op := m.vim.operator; op != "" {
+			m.vim.operator, m.vim.opCount = "", 0
+			// cw behaves like ce on a word, as in vim
+			if op == "c" && key == "w" && off < len(b.runes) && charClass(b.runes[off]) != 0 {
+				mo, _ = b.motion("e", off, count, explicit)
+			}
+			if mo.linewise {
+				start, end := b.lineBounds(off, mo.to)
+				return m.vimOperate(op, b, start, end, true)
+			}
+			start, end := min(off, mo.to), max(off, mo.to)
+			if mo.inclusive {
+				end = min(len(b.runes), end+1)
+			}
+			return m.vimOperate(op, b, start, end, false)
+		}
+		return m.vimMoveTo(b, mo.to), nil
+	}
+
+	m.vim.count = ""
+
+	if visual {
+		start, end := min(m.vim.anchor, off), max(m.vim.anchor, off)+1
+		linewise := m.vim.mode == vimVisualLine
+		if linewise {
+			start, end = b.lineBounds(start, end-1)
+		}
+		end = min(end, len(b.runes))
+		switch key {
+		case "esc", "v", "V":
+			m.vim.mode = vimNormal
+			return m, nil
+		case "d", "x":
+			m.vim.mode = vimNormal
+			return m.vimOperate("d", b, start, end, linewise)
+		case "y":
+			m.vim.mode = vimNormal
+			return m.vimOperate("y", b, start, end, linewise)
+		case "c":
+			m.vim.mode = vimNormal
+			return m.vimOperate("c", b, start, end, linewise)
+		}
+		return m, nil
+	}
+
+	if op := m.vim.operator; op != "" {
+		m.vim.operator, m.vim.opCount = "", 0
+		// dd, cc and yy work on count whole lines
+		if key == op {
+			last, _ := b.motion("j", off, count-1, false)
+			start, end := b.lineBounds(off, last.to)
+			return m.vimOperate(op, b, start, end, true)
+		}
+		return m, nil
+	}
+
+	switch key {
+	case "esc":
+		return m, nil
+	case "d", "c", "y":
+		m.vim.operator = key
+		m.vim.opCount = 0
+		if explicit {
+			m.vim.opCount = count
+		}
+	case "x":
+		if off < b.lineEnd(off) {
+			return m.vimOperate("d", b, off, min(b.lineEnd(off), off+count), false)
+		}
+	case "D", "C":
+		op := strings.ToLower(key)
+		return m.vimOperate(op, b, off, b.lineEnd(off), false)
+	case "p":
+		return m.vimPaste(b, off, false, count)
+	case "P":
+		return m.vimPaste(b, off, true, count)
+	case "u":
+		return m.undoEdit()
+	case "ctrl+r":
+		return m.redoEdit()
+	case "i":
+		m.vim.mode = vimInsert
+	case "a":
+		m.vim.mode = vimInsert
+		if off < b.lineEnd(off) {
+			off++
+		}
+		return m.vimMoveTo(b, off), nil
+	case "I":
+		m.vim.mode = vimInsert
+		mo, _ := b.motion("^", off, 1, false)
+		return m.vimMoveTo(b, mo.to), nil
+	case "A":
+		m.vim.mode = vimInsert
+		return m.vimMoveTo(b, b.lineEnd(off)), nil
+	case "o":
+		m.vim.mode = vimInsert
+		at := b.lineEnd(off)
+		return m.vimReplace(b, at, at, "\n", at+1)
+	case "O":
+		m.vim.mode = vimInsert
+		at := b.lineStart(off)
+		return m.vimReplace(b, at, at, "\n", at)
+	case "v":
+		m.vim.mode = vimVisual
+		m.vim.anchor = off
+	case "V":
+		m.vim.mode = vimVisualLine
+		m.vim.anchor = off
+	case ":":
+		m.vim.commandOn = true
+		m.vim.command = ""
+	}
+	return m, nil
+}
+
+// vimSelection returns the visual selection as rune offsets, or ok=false outside visual mode.
+func (m model) vimSelection() (start, end int, ok bool) {
+	if m.vim.mode != vimVisual && m.vim.mode != vimVisualLine {
+		return 0, 0, false
+	}
+	b, off := m.vimBufferState()
+	start, end = min(m.vim.anchor, off), min(len(b.runes), max(m.vim.anchor, off)+1)
+	if m.vim.mode == vimVisualLine {
+		start, end = b.lineStart(start), b.lineEnd(max(start, end-1))
+	}
+	return start, end, true
+}
+
+// visualSpans highlights the part of a line that falls inside the visual selection.
+func (m model) visualSpans(row int, line string) []span {
+	start, end, ok := m.vimSelection()
+	if !ok {
+		return nil
+	}
+	b, _ := m.vimBufferState()
+	lineStart := b.offset(row, 0)
+	lineEnd := lineStart + len([]rune(line))
+	if end <= lineStart || start > lineEnd {
+		return nil
+	}
+	style := lipgloss.NewStyle().Background(m.theme.Border)
+	return []span{{start: max(start, lineStart) - lineStart, end: min(end, lineEnd) - lineStart, style: style}}
+}
+
+// vimStatus is the mode indicator, or the ":" command line while it is open.
+func (m model) vimStatus() string {
+	if m.vim.commandOn {
+		return lipgloss.NewStyle().Foreground(m.theme.Text).MarginLeft(2).Render(":" + m.vim.command)
+	}
+	pending := m.vim.operator + m.vim.count
+	if m.vim.opCount > 0 {
+		pending = strconv.Itoa(m.vim.opCount) + pending
+	}
+	if m.vim.pendingG {
+		pending += "g"
+	}
+	return lipgloss.NewStyle().Foreground(m.theme.Primary).Bold(true).MarginLeft(2).Render("-- "+m.vim.mode.String()+" --") +
+		lipgloss.NewStyle().Foreground(m.theme.Muted).Render(" "+pending)
+}